@@ -0,0 +1,136 @@
+package retry
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// adaptive rate limiter constants, same as in AWS SDK "adaptive" retry mode
+const (
+	adaptiveSmooth        = 0.8
+	adaptiveBeta          = 0.7
+	adaptiveScaleConstant = 0.4
+	adaptiveMinFillRate   = 0.5
+	adaptiveMinCapacity   = 1
+)
+
+// AdaptiveRateLimiter is a client-side rate limiter of attempts adapting
+// to throttling responses, as in AWS SDK "adaptive" retry mode:
+// the send rate is reduced multiplicatively on throttling and grows back
+// along a cubic curve (CUBIC congestion control) on success.
+// The limiter is disabled until the first throttling response.
+// It is safe for concurrent use and is meant to be shared by all calls
+// to one service, see WithRateLimiter.
+type AdaptiveRateLimiter struct {
+	mu sync.Mutex
+
+	enabled         bool
+	fillRate        float64
+	maxCapacity     float64
+	currentCapacity float64
+	lastTimestamp   time.Time
+
+	measuredTxRate   float64
+	lastTxRateBucket float64
+	requestCount     int
+	lastMaxRate      float64
+	lastThrottleTime time.Time
+	timeWindow       float64
+}
+
+// NewAdaptiveRateLimiter returns disabled AdaptiveRateLimiter.
+func NewAdaptiveRateLimiter() *AdaptiveRateLimiter {
+	now := time.Now()
+	return &AdaptiveRateLimiter{
+		lastTimestamp:    now,
+		lastThrottleTime: now,
+		lastTxRateBucket: seconds(now),
+	}
+}
+
+// Acquire waits for a token to send an attempt,
+// it returns immediately until the first throttling response.
+func (l *AdaptiveRateLimiter) Acquire(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.enabled {
+		l.mu.Unlock()
+		return nil
+	}
+
+	l.refill(time.Now())
+	var wait time.Duration
+	if l.currentCapacity < 1 {
+		wait = time.Duration((1 - l.currentCapacity) / l.fillRate * float64(time.Second))
+	}
+	// the token is taken in advance, so concurrent callers queue up
+	l.currentCapacity--
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	return Sleep(ctx, wait)
+}
+
+// Update adjusts the send rate by the result of an attempt.
+func (l *AdaptiveRateLimiter) Update(throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.updateMeasuredRate(now)
+
+	var rate float64
+	if throttled {
+		rateToUse := l.measuredTxRate
+		if l.enabled {
+			rateToUse = math.Min(rateToUse, l.fillRate)
+		}
+		l.lastMaxRate = rateToUse
+		l.calculateTimeWindow()
+		l.lastThrottleTime = now
+		rate = rateToUse * adaptiveBeta
+		l.enabled = true
+	} else {
+		l.calculateTimeWindow()
+		t := now.Sub(l.lastThrottleTime).Seconds()
+		rate = adaptiveScaleConstant*math.Pow(t-l.timeWindow, 3) + l.lastMaxRate
+	}
+
+	l.updateRate(now, math.Min(rate, 2*l.measuredTxRate))
+}
+
+func (l *AdaptiveRateLimiter) refill(now time.Time) {
+	fill := now.Sub(l.lastTimestamp).Seconds() * l.fillRate
+	l.currentCapacity = math.Min(l.maxCapacity, l.currentCapacity+fill)
+	l.lastTimestamp = now
+}
+
+func (l *AdaptiveRateLimiter) updateRate(now time.Time, rate float64) {
+	l.refill(now)
+	l.fillRate = math.Max(rate, adaptiveMinFillRate)
+	l.maxCapacity = math.Max(rate, adaptiveMinCapacity)
+	l.currentCapacity = math.Min(l.currentCapacity, l.maxCapacity)
+}
+
+func (l *AdaptiveRateLimiter) calculateTimeWindow() {
+	l.timeWindow = math.Cbrt(l.lastMaxRate * (1 - adaptiveBeta) / adaptiveScaleConstant)
+}
+
+func (l *AdaptiveRateLimiter) updateMeasuredRate(now time.Time) {
+	t := math.Floor(seconds(now)*2) / 2
+	l.requestCount++
+	if t > l.lastTxRateBucket {
+		rate := float64(l.requestCount) / (t - l.lastTxRateBucket)
+		l.measuredTxRate = rate*adaptiveSmooth + l.measuredTxRate*(1-adaptiveSmooth)
+		l.requestCount = 0
+		l.lastTxRateBucket = t
+	}
+}
+
+// seconds returns Unix time in fractional seconds
+func seconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
@@ -0,0 +1,173 @@
+// Package backoff computes delays between attempts of failed operations
+// independently of any retry loop, e.g. to schedule redelivery of queue messages.
+package backoff
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// MaxDelay is the max delay computed by the package, growing delays
+// saturate at it, so jitter does not overflow time.Duration.
+const MaxDelay = time.Duration(math.MaxInt64 / 2)
+
+// Exp returns initial multiplied by 2 raised to zero-based attempt,
+// saturating at MaxDelay.
+func Exp(initial time.Duration, attempt int) time.Duration {
+	if attempt >= 62 || initial > MaxDelay>>attempt {
+		return MaxDelay
+	}
+	return initial << attempt
+}
+
+// ExpBy returns initial multiplied by multiplier raised to zero-based
+// attempt, saturating at MaxDelay, e.g. gRPC backoffMultiplier.
+func ExpBy(initial time.Duration, multiplier float64, attempt int) time.Duration {
+	d := float64(initial) * math.Pow(multiplier, float64(attempt))
+	if d >= float64(MaxDelay) {
+		return MaxDelay
+	}
+	return time.Duration(d)
+}
+
+// Log returns initial multiplied by the binary logarithm of zero-based
+// attempt plus 2, so the delay grows but flattens quickly:
+// 1, 1.58, 2, 2.32... times initial, saturating at MaxDelay.
+func Log(initial time.Duration, attempt int) time.Duration {
+	d := float64(initial) * math.Log2(float64(attempt)+2)
+	if d >= float64(MaxDelay) {
+		return MaxDelay
+	}
+	return time.Duration(d)
+}
+
+// Jittered returns d multiplied by a factor in range [1-jitter, 1+jitter),
+// picked by r expected to be random in range [0.0, 1.0).
+func Jittered(d time.Duration, jitter, r float64) time.Duration {
+	return time.Duration(float64(d) * (1 + jitter*(r*2-1)))
+}
+
+// JitteredBy returns d shifted by an offset in range [-jitter, jitter),
+// picked by r expected to be random in range [0.0, 1.0), non-negative.
+func JitteredBy(d, jitter time.Duration, r float64) time.Duration {
+	d += time.Duration(float64(jitter) * (r*2 - 1))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Decorrelated returns the delay following prev of decorrelated jitter
+// backoff: between initial and 3 times prev, picked by r expected
+// to be random in range [0.0, 1.0).
+func Decorrelated(initial, prev time.Duration, r float64) time.Duration {
+	upper := MaxDelay
+	if prev < MaxDelay/3 {
+		upper = 3 * prev
+	}
+	if upper < initial {
+		upper = initial
+	}
+	return initial + time.Duration(r*float64(upper-initial))
+}
+
+// Capped returns d limited by max, non-positive max means no limit.
+func Capped(d, max time.Duration) time.Duration {
+	if max > 0 && d > max {
+		return max
+	}
+	return d
+}
+
+// Schedule returns the delay after zero-based failed attempt.
+type Schedule func(attempt int) time.Duration
+
+// Constant returns Schedule of the same delay d after every attempt.
+func Constant(d time.Duration) Schedule {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// Linear returns Schedule of delay growing by step after every attempt,
+// starting with initial.
+func Linear(initial, step time.Duration) Schedule {
+	return func(attempt int) time.Duration {
+		if step > 0 && time.Duration(attempt) > (MaxDelay-initial)/step {
+			return MaxDelay
+		}
+		return initial + time.Duration(attempt)*step
+	}
+}
+
+// Exponential returns Schedule of delay doubling after every attempt,
+// starting with initial, see Exp.
+func Exponential(initial time.Duration) Schedule {
+	return func(attempt int) time.Duration {
+		return Exp(initial, attempt)
+	}
+}
+
+// Logarithmic returns Schedule of delay growing logarithmically
+// after every attempt, starting with initial, see Log.
+func Logarithmic(initial time.Duration) Schedule {
+	return func(attempt int) time.Duration {
+		return Log(initial, attempt)
+	}
+}
+
+// Steps returns Schedule of explicit delays, the last one is repeated
+// after the steps are over.
+func Steps(delays ...time.Duration) Schedule {
+	return func(attempt int) time.Duration {
+		switch {
+		case len(delays) == 0:
+			return 0
+		case attempt < len(delays):
+			return delays[attempt]
+		default:
+			return delays[len(delays)-1]
+		}
+	}
+}
+
+// Jitter returns Schedule randomizing delays of s by jitter, see Jittered.
+func (s Schedule) Jitter(jitter float64) Schedule {
+	return func(attempt int) time.Duration {
+		return Jittered(s(attempt), jitter, rand.Float64())
+	}
+}
+
+// Cap returns Schedule of delays of s limited by max, see Capped.
+func (s Schedule) Cap(max time.Duration) Schedule {
+	return func(attempt int) time.Duration {
+		return Capped(s(attempt), max)
+	}
+}
+
+// Then returns Schedule of delays of s for the first n attempts,
+// and of delays of next after them, counting attempts of next from zero.
+func (s Schedule) Then(n int, next Schedule) Schedule {
+	return func(attempt int) time.Duration {
+		if attempt < n {
+			return s(attempt)
+		}
+		return next(attempt - n)
+	}
+}
+
+// Aligned returns delay from now extended to the next wall clock boundary,
+// a multiple of boundary since the zero time, e.g. the top of a minute
+// or the next :00/:15/:30/:45 second. Non-positive boundary keeps delay.
+func Aligned(now time.Time, delay, boundary time.Duration) time.Duration {
+	if boundary <= 0 {
+		return delay
+	}
+	at := now.Add(delay)
+	aligned := at.Truncate(boundary)
+	if aligned.Before(at) {
+		aligned = aligned.Add(boundary)
+	}
+	return aligned.Sub(now)
+}
@@ -0,0 +1,52 @@
+package backoff_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/osvim/retry/backoff"
+)
+
+func ExampleSchedule() {
+	schedule := backoff.Constant(0).Then(1, backoff.Exponential(100*time.Millisecond).Cap(time.Second))
+
+	for attempt := 0; attempt < 7; attempt++ {
+		fmt.Println(schedule(attempt))
+	}
+	// Output:
+	// 0s
+	// 100ms
+	// 200ms
+	// 400ms
+	// 800ms
+	// 1s
+	// 1s
+}
+
+func ExampleJittered() {
+	fmt.Println(backoff.Jittered(time.Second, 0.2, 0), backoff.Jittered(time.Second, 0.2, 0.5))
+	// Output: 800ms 1s
+}
+
+func ExampleAligned() {
+	now := time.Date(2020, 1, 1, 10, 0, 7, 0, time.UTC)
+
+	fmt.Println(backoff.Aligned(now, time.Second, 15*time.Second))
+	fmt.Println(backoff.Aligned(now, 10*time.Second, time.Minute))
+	// Output:
+	// 8s
+	// 53s
+}
+
+func ExampleBusinessHours() {
+	hours := backoff.BusinessHours(time.UTC)
+
+	friday := time.Date(2020, 1, 3, 18, 30, 0, 0, time.UTC)
+	monday := time.Date(2020, 1, 6, 11, 0, 0, 0, time.UTC)
+
+	fmt.Println(hours.Next(friday))
+	fmt.Println(hours.Next(monday))
+	// Output:
+	// 2020-01-06 09:00:00 +0000 UTC
+	// 2020-01-06 11:00:00 +0000 UTC
+}
@@ -0,0 +1,73 @@
+package backoff
+
+import "time"
+
+// Hours is a daily time window, e.g. business hours of a partner system.
+type Hours struct {
+	// Days are the days of the window, every day if empty.
+	Days []time.Weekday
+	// From and To are the offsets since midnight of the start
+	// and the end of the window, To is excluded.
+	From, To time.Duration
+	// Location is the time zone of the window, UTC if nil.
+	Location *time.Location
+}
+
+// BusinessHours returns Hours from 9:00 to 17:00 on weekdays in loc.
+func BusinessHours(loc *time.Location) Hours {
+	return Hours{
+		Days:     []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		From:     9 * time.Hour,
+		To:       17 * time.Hour,
+		Location: loc,
+	}
+}
+
+// Next returns the earliest time not before t within the window,
+// t if the window is empty, i.e. From is not before To.
+func (h Hours) Next(t time.Time) time.Time {
+	if h.From >= h.To {
+		return t
+	}
+
+	loc := h.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+
+	// a week and a day, so the window of today is checked again next week
+	for day := 0; day <= 7; day++ {
+		midnight := time.Date(local.Year(), local.Month(), local.Day()+day, 0, 0, 0, 0, loc)
+		if !h.on(midnight.Weekday()) {
+			continue
+		}
+
+		open, closed := midnight.Add(h.From), midnight.Add(h.To)
+		switch {
+		case t.Before(open):
+			return open
+		case t.Before(closed):
+			return t
+		}
+	}
+	return t
+}
+
+// Until returns delay from now extended until the window, see Next.
+func (h Hours) Until(now time.Time, delay time.Duration) time.Duration {
+	return h.Next(now.Add(delay)).Sub(now)
+}
+
+// on reports whether the window is open on weekday
+func (h Hours) on(weekday time.Weekday) bool {
+	if len(h.Days) == 0 {
+		return true
+	}
+	for _, day := range h.Days {
+		if day == weekday {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+//go:build go1.23
+
+package backoff
+
+import (
+	"iter"
+	"time"
+)
+
+// Seq returns the delays of Schedule after the failed attempts
+// of maxAttempts, the last attempt is not followed by a delay.
+func (s Schedule) Seq(maxAttempts int) iter.Seq[time.Duration] {
+	return func(yield func(time.Duration) bool) {
+		for attempt := 0; attempt < maxAttempts-1; attempt++ {
+			if !yield(s(attempt)) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package retry
+
+import (
+	"context"
+	"time"
+)
+
+// Stop is returned by BackOff.NextBackOff to stop retrying,
+// same as backoff.Stop of github.com/cenkalti/backoff.
+const Stop time.Duration = -1
+
+// BackOff computes delays between attempts, it is the interface of
+// github.com/cenkalti/backoff, so its implementations can be used
+// by DoBackOff, and Retry can be passed to code written against it
+// by Retry.BackOff.
+type BackOff interface {
+	// NextBackOff returns the delay before the next attempt, or Stop.
+	NextBackOff() time.Duration
+	// Reset restores the initial state.
+	Reset()
+}
+
+// BackOff returns BackOff of the delays of the policy, which returns Stop
+// when attempts are over. Jitter and decorrelated backoff are applied,
+// other features of the policy are left to the loop using BackOff.
+// BackOff is not safe for concurrent use.
+func (r Retry) BackOff() BackOff {
+	return &policyBackOff{policy: r}
+}
+
+// policyBackOff is BackOff of Retry
+type policyBackOff struct {
+	policy Retry
+	state  backoffState
+}
+
+func (b *policyBackOff) NextBackOff() time.Duration {
+	if b.state.failures >= b.policy.attempts-1 {
+		return Stop
+	}
+	delay, _ := b.policy.delayAfter(&b.state, nil)
+	return delay
+}
+
+func (b *policyBackOff) Reset() {
+	b.state.reset()
+}
+
+// DoBackOff works same as Retry.Do, but sleeps for delays of b, e.g.
+// an ExponentialBackOff of github.com/cenkalti/backoff, until it returns
+// Stop. Attempts of the policy limit the calls as well, b is reset first.
+// Do gives up on Stop as if attempts were over: OnExhausted and Recorder
+// of the policy observe it and the error is built by ExhaustedError.
+func DoBackOff(ctx context.Context, policy Retry, b BackOff, call Func) error {
+	b.Reset()
+
+	var attempts int
+	return policy.Do(ctx, func() (bool, error) {
+		retry, err := call()
+		attempts++
+		if !retry || err == nil || aborted(err) {
+			return retry, err
+		}
+
+		delay := b.NextBackOff()
+		if delay == Stop {
+			policy.exhausted(ctx, attempts, err)
+			return false, policy.exhaustedError(attempts, err)
+		}
+		return true, RetryAfter(err, delay)
+	})
+}
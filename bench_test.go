@@ -0,0 +1,72 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/osvim/retry"
+)
+
+var errTemporary = errors.New("temporary")
+
+func succeed() (bool, error) { return false, nil }
+
+// failOnce returns retry.Func failing every other call
+func failOnce() retry.Func {
+	var failed bool
+	return func() (bool, error) {
+		failed = !failed
+		return failed, errTemporary
+	}
+}
+
+func BenchmarkRetry_Do(b *testing.B) {
+	ctx := context.Background()
+	policy := retry.Attempts(3).ExponentialJitterBackoff(time.Nanosecond, 0.1)
+
+	b.Run("success", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = policy.Do(ctx, succeed)
+		}
+	})
+
+	b.Run("success without backoff", func(b *testing.B) {
+		policy := retry.Attempts(3)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = policy.Do(ctx, succeed)
+		}
+	})
+
+	b.Run("one retry", func(b *testing.B) {
+		call := failOnce()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = policy.Do(ctx, call)
+		}
+	})
+}
+
+func BenchmarkDo(b *testing.B) {
+	ctx := context.Background()
+
+	b.Run("success", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = retry.Do(ctx, succeed, retry.WithAttempts(3), retry.WithBackoff(time.Nanosecond),
+				retry.WithExponential(), retry.WithJitter(0.1))
+		}
+	})
+
+	b.Run("one retry", func(b *testing.B) {
+		call := failOnce()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = retry.Do(ctx, call, retry.WithAttempts(3), retry.WithBackoff(time.Nanosecond),
+				retry.WithExponential(), retry.WithJitter(0.1))
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBudgetExceeded is matched by errors.Is when Do gives up
+// because the global retry budget is exceeded, see SetGlobalBudget.
+var ErrBudgetExceeded = errors.New("retry budget exceeded")
+
+// SetGlobalBudget limits the total number of retries per second
+// of all Retry policies in the process. When the budget is exceeded,
+// Do returns the last error immediately instead of retrying,
+// so a retry storm does not amplify an outage.
+// The burst is one second of retries, at least one retry, so fractional
+// rates allow a retry every few seconds. Non-positive retriesPerSecond
+// removes the limit.
+func SetGlobalBudget(retriesPerSecond float64) {
+	if retriesPerSecond <= 0 {
+		globalBudget.Store((*tokenBucket)(nil))
+		return
+	}
+	globalBudget.Store(newTokenBucket(retriesPerSecond, math.Max(retriesPerSecond, 1)))
+}
+
+// Budget limits the number of retries per second, e.g. of calls to one
+// destination, its checks are left to the caller, unlike SetGlobalBudget.
+// Budget is safe for concurrent use.
+type Budget struct {
+	bucket *tokenBucket
+}
+
+// NewBudget returns Budget of retriesPerSecond with the burst of one second,
+// at least one retry.
+func NewBudget(retriesPerSecond float64) *Budget {
+	return &Budget{bucket: newTokenBucket(retriesPerSecond, math.Max(retriesPerSecond, 1))}
+}
+
+// Allow reports whether one more retry is allowed and takes it from the budget.
+func (b *Budget) Allow() bool {
+	return b.bucket.take(time.Now())
+}
+
+// globalBudget holds *tokenBucket limiting retries process-wide
+var globalBudget atomic.Value
+
+// allowGlobalRetry reports whether the global budget allows one more retry
+func allowGlobalRetry() bool {
+	bucket, _ := globalBudget.Load().(*tokenBucket)
+	return bucket == nil || bucket.take(time.Now())
+}
+
+// tokenBucket is a thread-safe token bucket
+type tokenBucket struct {
+	mu sync.Mutex
+	// rate is the number of tokens added per second
+	rate float64
+	// burst is the max number of tokens
+	burst float64
+	// tokens is the number of tokens available at last
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// take removes a token from the bucket, if any
+func (b *tokenBucket) take(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type budgetExceeded struct {
+	reason error
+}
+
+func (e budgetExceeded) Error() string {
+	if e.reason != nil {
+		return fmt.Sprintf("%s: %s", ErrBudgetExceeded.Error(), e.reason.Error())
+	}
+	return ErrBudgetExceeded.Error()
+}
+
+func (e budgetExceeded) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+func (e budgetExceeded) Unwrap() error {
+	return e.reason
+}
@@ -0,0 +1,64 @@
+package retry
+
+// Then returns a copy of Retry continuing with the schedule of next
+// when its attempts are over, as one Do call: next makes up to its attempts
+// more Func calls with its backoff, e.g. 3 quick retries followed by 5 slow
+// ones. Other settings, e.g. hooks and timeouts, are taken from Retry.
+func (r Retry) Then(next Retry) Retry {
+	chain := make([]Retry, 0, len(r.next)+1+len(next.next))
+	chain = append(chain, r.next...)
+	chain = append(chain, next.withoutChain())
+	chain = append(chain, next.next...)
+	r.next = chain
+	return r
+}
+
+// withoutChain returns a copy of Retry without policies added by Then
+func (r Retry) withoutChain() Retry {
+	r.next = nil
+	return r
+}
+
+// stages is the state of the policies chained by Then of a Do call
+type stages struct {
+	// index is the index of the current policy, 0 is Retry itself
+	index int
+	// start is the zero-based attempt the current policy started from
+	start int
+	state backoffState
+}
+
+// stage returns the policy of the retry after zero-based attempt,
+// its backoff state and whether the attempts of all policies are over
+func (r Retry) stage(s *stages, attempt int, state *backoffState) (Retry, *backoffState, bool) {
+	policy, policyState, attempts := r, state, r.scaledAttempts(r.attempts)
+	if s.index > 0 {
+		policy, policyState, attempts = r.next[s.index-1], &s.state, r.next[s.index-1].attempts
+	}
+
+	for attempt+1-s.start >= attempts {
+		if s.index == len(r.next) {
+			return policy, policyState, true
+		}
+
+		s.index++
+		s.start, s.state = attempt+1, backoffState{}
+		policy, policyState, attempts = r.next[s.index-1], &s.state, r.next[s.index-1].attempts
+		// decisions are traced by the writer of the chain
+		policy.explain = r.explain
+		if r.explain != nil {
+			r.explainf("continuing with %s", policy)
+		}
+	}
+	return policy, policyState, false
+}
+
+// stageLeft returns the number of attempts left in the current stage
+// before zero-based attempt, including it
+func (r *Retry) stageLeft(s *stages, attempt int) int {
+	attempts := r.scaledAttempts(r.attempts)
+	if s.index > 0 {
+		attempts = r.next[s.index-1].attempts
+	}
+	return attempts - (attempt - s.start)
+}
@@ -0,0 +1,35 @@
+package retry
+
+// Class is a class of errors with its own budget of retries,
+// e.g. "timeout", "throttling" or "auth", see WithAttemptsByClass.
+type Class string
+
+// ClassOf returns the class of err, an empty class for unclassified errors.
+type ClassOf func(err error) Class
+
+// classRetries counts retries of a Do call per error class
+type classRetries map[Class]int
+
+// allowClass reports whether err of the class may be retried once more
+// in the Do call, counting the retry
+func (r Retry) allowClass(retries *classRetries, err error) bool {
+	if r.classOf == nil {
+		return true
+	}
+
+	class := r.classOf(err)
+	limit, ok := r.attemptsByClass[class]
+	if !ok {
+		return true
+	}
+	if (*retries)[class] >= limit {
+		r.explainf("retry declined: %d retries of class %q", limit, class)
+		return false
+	}
+
+	if *retries == nil {
+		*retries = make(classRetries, len(r.attemptsByClass))
+	}
+	(*retries)[class]++
+	return true
+}
@@ -0,0 +1,161 @@
+package retry
+
+import (
+	"errors"
+	"time"
+)
+
+// Classifier reports whether err is temporary,
+// so the failed call should be retried.
+type Classifier func(err error) bool
+
+// Always adapts fn to Func retrying every error.
+func Always(fn func() error) Func {
+	return Classify(fn, nil)
+}
+
+// Classify adapts fn to Func retrying errors classified
+// as temporary by isRetryable, every error is retried if it is nil.
+func Classify(fn func() error, isRetryable Classifier) Func {
+	return func() (bool, error) {
+		if err := fn(); err != nil {
+			return classify(err, isRetryable), err
+		}
+		return false, nil
+	}
+}
+
+// Retryable marks err as temporary, so it is retried by Classify, Ctx
+// and other classifier-based adapters regardless of the classifier,
+// e.g. context.DeadlineExceeded of a sub-call.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return retryableError{err}
+}
+
+type retryableError struct {
+	error
+}
+
+func (e retryableError) Unwrap() error {
+	return e.error
+}
+
+// classify reports whether err should be retried
+func classify(err error, classifier Classifier) bool {
+	if aborted(err) {
+		return false
+	}
+	var marked retryableError
+	if errors.As(err, &marked) {
+		return true
+	}
+	if _, ok := requestedDelay(err); ok {
+		return true
+	}
+	return classifier == nil || classifier(err)
+}
+
+// ErrAborted is returned by Do stopped by Abort(nil).
+var ErrAborted = errors.New("retry: aborted")
+
+// Abort stops Do, even Forever, when returned by Func, ContextFunc or
+// a classifier-based adapter; Do returns err, or ErrAborted if err is nil.
+func Abort(err error) error {
+	if err == nil {
+		err = ErrAborted
+	}
+	return abortError{err}
+}
+
+type abortError struct {
+	error
+}
+
+func (e abortError) Unwrap() error {
+	return e.error
+}
+
+// RetryAfter marks err as temporary and requests the next attempt
+// after delay instead of the backoff of the policy,
+// e.g. delay requested by server in Retry-After header or gRPC pushback.
+func RetryAfter(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return retryAfterError{error: err, delay: delay}
+}
+
+type retryAfterError struct {
+	error
+	delay time.Duration
+}
+
+func (e retryAfterError) Unwrap() error {
+	return e.error
+}
+
+// requestedDelay finds the delay passed to RetryAfter in the chain of err
+func requestedDelay(err error) (time.Duration, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if after, ok := err.(retryAfterError); ok {
+			return after.delay, true
+		}
+	}
+	return 0, false
+}
+
+// aborted reports whether err is returned by Abort
+func aborted(err error) bool {
+	_, ok := abortCause(err)
+	return ok
+}
+
+// unwrapAbort returns the error passed to Abort, if any
+func unwrapAbort(err error) error {
+	if cause, ok := abortCause(err); ok {
+		return cause
+	}
+	return err
+}
+
+// abortCause finds the error passed to Abort in the chain of err,
+// it walks the chain by hand, as errors.As allocates on the hot path
+func abortCause(err error) (error, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if abort, ok := err.(abortError); ok {
+			return abort.error, true
+		}
+	}
+	return nil, false
+}
+
+// FailedOn attaches the resource used by the failed attempt to err,
+// e.g. a pooled connection, see Config.OnRetryableFailure.
+func FailedOn(err error, resource interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return resourceError{error: err, resource: resource}
+}
+
+type resourceError struct {
+	error
+	resource interface{}
+}
+
+func (e resourceError) Unwrap() error {
+	return e.error
+}
+
+// failedResource finds the resource passed to FailedOn in the chain of err
+func failedResource(err error) interface{} {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if failed, ok := err.(resourceError); ok {
+			return failed.resource
+		}
+	}
+	return nil
+}
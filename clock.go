@@ -0,0 +1,84 @@
+package retry
+
+import "time"
+
+// Clock is the source of time of Retry, SystemClock by default.
+// Retry measures durations only as Since of a time returned by Now,
+// never as a difference of wall clock readings, so with SystemClock
+// attempt durations and deadlines are measured by the monotonic clock
+// and are not corrupted by NTP steps or VM pauses.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Since returns the time elapsed since t returned by Now.
+	Since(t time.Time) time.Duration
+	// NewTimer returns Timer firing after d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer fires once after its duration, see time.Timer.
+type Timer interface {
+	// C returns the channel receiving the time when Timer fires.
+	C() <-chan time.Time
+	// Stop prevents Timer from firing, reports whether it was stopped
+	// before firing.
+	Stop() bool
+}
+
+// SystemClock is Clock of the time package, readings of Now carry
+// the monotonic clock.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// now returns the current time of the clock of Retry
+func (r *Retry) now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+	return r.clock.Now()
+}
+
+// since returns the time elapsed since t by the clock of Retry
+func (r *Retry) since(t time.Time) time.Duration {
+	if r.clock == nil {
+		return time.Since(t)
+	}
+	return r.clock.Since(t)
+}
+
+// until returns the duration until deadline by the clock of Retry
+func (r Retry) until(deadline time.Time) time.Duration {
+	if r.clock == nil {
+		return time.Until(deadline)
+	}
+	return -r.clock.Since(deadline)
+}
+
+// timer returns Timer firing after d by the clock of Retry
+func (r Retry) timer(d time.Duration) Timer {
+	if r.clock == nil {
+		return SystemClock.NewTimer(d)
+	}
+	return r.clock.NewTimer(d)
+}
@@ -0,0 +1,32 @@
+package main
+
+import "os"
+
+func Example() {
+	_ = run([]string{`{"attempts":4,"backoff":"exponential","initial":"100ms","cap":"1s","jitter":0.2}`}, nil, os.Stdout)
+	// Output:
+	// policy: 4 attempts, exponential backoff 100ms..400ms, jitter 20%
+	// retry  delay  min    max    worst-case total
+	// 1      100ms  80ms   120ms  120ms
+	// 2      200ms  160ms  240ms  360ms
+	// 3      400ms  320ms  480ms  840ms
+}
+
+func Example_immediateRetries() {
+	_ = run([]string{`{"attempts":4,"backoff":"exponential","initial":"100ms","min":"500ms","immediate_retries":2}`}, nil, os.Stdout)
+	// Output:
+	// policy: 4 attempts, 2 immediate retries, exponential backoff 500ms..500ms, min backoff 500ms
+	// retry  delay  min    max    worst-case total
+	// 1      0s     0s     0s     0s
+	// 2      0s     0s     0s     0s
+	// 3      500ms  500ms  500ms  500ms
+}
+
+func Example_exactFirstRetry() {
+	_ = run([]string{`{"attempts":3,"backoff":"linear","initial":"100ms","jitter":0.5,"exact_first_retry":true}`}, nil, os.Stdout)
+	// Output:
+	// policy: 3 attempts, linear backoff 100ms, jitter 50%, exact first retry
+	// retry  delay  min    max    worst-case total
+	// 1      100ms  100ms  100ms  100ms
+	// 2      100ms  50ms   150ms  250ms
+}
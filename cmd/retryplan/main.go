@@ -0,0 +1,75 @@
+// Command retryplan prints the delay schedule of a retry policy,
+// the jitter bounds of every delay and the cumulative worst-case time,
+// e.g. for code reviews and runbooks. The policy is given in the JSON
+// representation of retry.Retry, as an argument or on stdin:
+//
+//	$ retryplan '{"attempts":4,"backoff":"exponential","initial":"100ms","cap":"1s","jitter":0.2}'
+//	policy: 4 attempts, exponential backoff 100ms..400ms, jitter 20%
+//	retry  delay  min    max    worst-case total
+//	1      100ms  80ms   120ms  120ms
+//	2      200ms  160ms  240ms  360ms
+//	3      400ms  320ms  480ms  840ms
+//
+// The worst-case total is the time before the retry starts,
+// including attempt timeouts, if set.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/osvim/retry"
+)
+
+// maxRows limits the schedule of unlimited policies
+const maxRows = 20
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "retryplan:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	var spec []byte
+	switch len(args) {
+	case 0:
+		var err error
+		if spec, err = io.ReadAll(stdin); err != nil {
+			return err
+		}
+	case 1:
+		spec = []byte(args[0])
+	default:
+		return fmt.Errorf("usage: retryplan [policy JSON]")
+	}
+
+	var cfg retry.Config
+	if err := json.Unmarshal(spec, &cfg); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	policy := retry.New(cfg)
+	fmt.Fprintln(stdout, "policy:", policy)
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "retry\tdelay\tmin\tmax\tworst-case total")
+
+	var total time.Duration
+	for attempt := 0; attempt < policy.MaxAttempts()-1 && attempt < maxRows; attempt++ {
+		min, max := policy.BackoffRangeAt(attempt)
+		total += max + cfg.AttemptTimeout
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", attempt+1, policy.BackoffAt(attempt), min, max, total)
+	}
+	if policy.MaxAttempts()-1 > maxRows {
+		fmt.Fprintf(w, "...\t\t\t\t\n")
+	}
+	return w.Flush()
+}
@@ -0,0 +1,171 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/osvim/retry/backoff"
+)
+
+// ContextFunc is a retryable function receiving the context of the call,
+// see Func for the meaning of the returned values.
+type ContextFunc func(ctx context.Context) (retry bool, err error)
+
+// Ctx adapts fn to ContextFunc retrying errors classified
+// as temporary by classifier, every error is retried if it is nil.
+func Ctx(fn func(ctx context.Context) error, classifier Classifier) ContextFunc {
+	return func(ctx context.Context) (bool, error) {
+		if err := fn(ctx); err != nil {
+			return classify(err, classifier), err
+		}
+		return false, nil
+	}
+}
+
+// DoContext works same as Retry.DoContext
+func DoContext(ctx context.Context, call ContextFunc, opts ...Option) error {
+	return New(Config{}.With(opts...)).DoContext(ctx, call)
+}
+
+// DoContext works same as Retry.Do, but passes ctx to call,
+// limited by AttemptTimeout if it is set.
+func (r Retry) DoContext(ctx context.Context, call ContextFunc) error {
+	r.override(ctx)
+
+	var (
+		state backoffState
+		scope attemptScope
+	)
+	return r.do(ctx, func() (bool, error) {
+		return scope.policy.call(ctx, scope, call)
+	}, &state, nil, &scope)
+}
+
+// attemptScope is the scope of the current attempt of DoContext,
+// it is set by run before each attempt
+type attemptScope struct {
+	// policy is the policy of the attempt, reloaded by DynamicRetry,
+	// copied so Retry of run doesn't escape to the heap
+	policy Retry
+	// attempt is the zero-based attempt of the Do call
+	attempt int
+	// left is the number of attempts left in the current stage of Then
+	// or route of Switch, including the current one
+	left int
+}
+
+// call calls ContextFunc with the context of the attempt
+func (r Retry) call(ctx context.Context, scope attemptScope, call ContextFunc) (bool, error) {
+	attemptCtx := ctx
+	if timeout := r.timeout(ctx, scope.attempt, scope.left); timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	retry, err := call(attemptCtx)
+	return r.onDeadline.decide(ctx, retry, err)
+}
+
+// deadlineDecision is the decision on errors of exceeded deadlines,
+// see Retry.RetryOnDeadline
+type deadlineDecision uint8
+
+const (
+	// classifyDeadline leaves the decision to the classifier
+	classifyDeadline deadlineDecision = iota
+	// retryDeadline retries deadlines of attempts
+	retryDeadline
+	// skipDeadline retries no deadlines
+	skipDeadline
+)
+
+// decide decides on the result of ContextFunc called with the context
+// derived from parent
+func (d deadlineDecision) decide(parent context.Context, retry bool, err error) (bool, error) {
+	if d == classifyDeadline || !errors.Is(err, context.DeadlineExceeded) || aborted(err) {
+		return retry, err
+	}
+	return d == retryDeadline && parent.Err() == nil, err
+}
+
+// config returns the decision as Config.RetryOnDeadline
+func (d deadlineDecision) config() *bool {
+	if d == classifyDeadline {
+		return nil
+	}
+	retry := d == retryDeadline
+	return &retry
+}
+
+// timeout returns the limit of the zero-based attempt duration, if any,
+// left is the number of attempts left including the current one
+func (r Retry) timeout(ctx context.Context, attempt, left int) time.Duration {
+	timeout := r.attemptTimeout
+	if r.progressiveTimeout && timeout > 0 {
+		timeout = backoff.Exp(timeout, attempt)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && r.deadlineSpread && r.attempts != Unlimited {
+		if left < 1 {
+			left = 1
+		}
+		spread := r.until(deadline) / time.Duration(left)
+		if spread > 0 && (timeout <= 0 || spread < timeout) {
+			timeout = spread
+		}
+	}
+
+	return timeout
+}
+
+// disableRetriesKey is the context key of DisableRetries
+type disableRetriesKey struct{}
+
+// DisableRetries returns a copy of ctx making Do call Func once,
+// whatever the policy is, e.g. in tests and admin endpoints.
+func DisableRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, disableRetriesKey{}, true)
+}
+
+// retriesDisabled reports whether ctx is returned by DisableRetries
+func retriesDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(disableRetriesKey{}).(bool)
+	return disabled
+}
+
+// Overrides are per-request overrides of the policy carried in context,
+// zero fields keep the policy.
+type Overrides struct {
+	// Attempts overrides the max number of Func calls, e.g. 1 to disable
+	// retries for a tenant.
+	Attempts int
+	// MaxBackoff overrides the cap of the delay after failed Func call.
+	MaxBackoff time.Duration
+}
+
+// overridesKey is the context key of Overrides
+type overridesKey struct{}
+
+// WithContextOverrides returns a copy of ctx making Do apply overrides
+// to whatever policy it is called with, e.g. across library boundaries.
+// Overrides of ctx replace the overrides of its parents.
+func WithContextOverrides(ctx context.Context, overrides Overrides) context.Context {
+	return context.WithValue(ctx, overridesKey{}, overrides)
+}
+
+// override applies overrides of ctx to Retry, a copy owned by the caller
+func (r *Retry) override(ctx context.Context) {
+	overrides, ok := ctx.Value(overridesKey{}).(Overrides)
+	if !ok {
+		return
+	}
+
+	if overrides.Attempts > 0 {
+		r.attempts = overrides.Attempts
+	}
+	if overrides.MaxBackoff > 0 {
+		r.maxBackoff = overrides.MaxBackoff
+	}
+}
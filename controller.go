@@ -0,0 +1,123 @@
+package retry
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Controller controls Do running in background, see Retry.Start.
+type Controller struct {
+	cancel context.CancelFunc
+	wake   chan struct{}
+	done   chan struct{}
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Status is the state of Do controlled by Controller.
+type Status struct {
+	// Attempts is the number of started attempts.
+	Attempts int
+	// InAttempt is true while Func is being called,
+	// false while Do sleeps in backoff.
+	InAttempt bool
+	// LastErr is the error of the last finished attempt.
+	LastErr error
+	// Done is true when Do returned Err.
+	Done bool
+	Err  error
+}
+
+// Start calls Do in background and returns Controller of it,
+// e.g. to nudge a stuck reconnect loop from an admin endpoint.
+func (r Retry) Start(ctx context.Context, call Func) *Controller {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &Controller{
+		cancel: cancel,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	if r.wake != nil {
+		go c.forward(ctx, r.wake)
+	}
+
+	go func() {
+		err := r.Wake(c.wake).Recorder(r.recorder, c).Do(ctx, call)
+
+		c.mu.Lock()
+		c.status.InAttempt, c.status.Done, c.status.Err = false, true, err
+		c.mu.Unlock()
+
+		cancel()
+		close(c.done)
+	}()
+
+	return c
+}
+
+// RetryNow interrupts the current backoff sleep, so Func is called immediately.
+func (c *Controller) RetryNow() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Cancel cancels the context of Do, see Wait for the result.
+func (c *Controller) Cancel() {
+	c.cancel()
+}
+
+// Status returns the current state of Do.
+func (c *Controller) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// Done is closed when Do returns.
+func (c *Controller) Done() <-chan struct{} {
+	return c.done
+}
+
+// Wait waits for Do to return and returns its error.
+func (c *Controller) Wait() error {
+	<-c.done
+	return c.Status().Err
+}
+
+// forward passes wake signals of the policy to Controller
+func (c *Controller) forward(ctx context.Context, wake <-chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-wake:
+			if !ok {
+				wake = nil
+			}
+			c.RetryNow()
+		}
+	}
+}
+
+// AttemptStarted implements Recorder.
+func (c *Controller) AttemptStarted(_ context.Context, _ int) {
+	c.mu.Lock()
+	c.status.Attempts++
+	c.status.InAttempt = true
+	c.mu.Unlock()
+}
+
+// AttemptEnded implements Recorder.
+func (c *Controller) AttemptEnded(_ context.Context, _ int, err error, _ time.Duration) {
+	c.mu.Lock()
+	c.status.InAttempt, c.status.LastErr = false, err
+	c.mu.Unlock()
+}
+
+// Exhausted implements Recorder.
+func (c *Controller) Exhausted(context.Context, int, error) {}
@@ -0,0 +1,44 @@
+package retry
+
+import "context"
+
+// Coordinator shares the consecutive failures of a dependency among
+// replicas, e.g. in Redis, so replicas retrying the same dependency
+// escalate a single backoff instead of each retrying independently.
+// Coordinator must be safe for concurrent use.
+type Coordinator interface {
+	// Failed records a retryable failure and returns the number of
+	// consecutive failures recorded by all replicas.
+	Failed(ctx context.Context) (failures int, err error)
+	// Succeeded resets the consecutive failures.
+	Succeeded(ctx context.Context) error
+}
+
+// coordinateFailure records the failure by the coordinator, if any, and
+// continues the backoff from the failures of all replicas. The local state
+// is kept when the coordinator is unavailable.
+func (r Retry) coordinateFailure(ctx context.Context, state *backoffState) {
+	if r.coordinator == nil {
+		return
+	}
+
+	failures, err := r.coordinator.Failed(ctx)
+	if err != nil || failures < 1 {
+		r.explainf("coordinator failed: %v; using local backoff", err)
+		return
+	}
+
+	state.lock()
+	state.failures = failures - 1
+	state.unlock()
+}
+
+// coordinateSuccess resets the failures of all replicas, if coordinated
+func (r *Retry) coordinateSuccess(ctx context.Context) {
+	if r.coordinator == nil {
+		return
+	}
+	if err := r.coordinator.Succeeded(ctx); err != nil {
+		r.explainf("coordinator failed: %v", err)
+	}
+}
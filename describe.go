@@ -0,0 +1,392 @@
+package retry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/osvim/retry/backoff"
+)
+
+// String describes the effective policy of Retry,
+// e.g. "5 attempts, exponential backoff 100ms..1.6s, jitter 20%".
+func (r Retry) String() string {
+	var b strings.Builder
+
+	switch r.attempts {
+	case Unlimited:
+		b.WriteString("unlimited attempts")
+	case 1:
+		b.WriteString("1 attempt")
+	default:
+		fmt.Fprintf(&b, "%d attempts", r.attempts)
+	}
+
+	if r.immediateRetries > 0 && r.attempts > 1 {
+		fmt.Fprintf(&b, ", %d immediate retries", r.immediateRetries)
+	}
+
+	first, last, ok := r.backoffRange()
+	switch {
+	case !ok:
+		b.WriteString(", no backoff")
+	case r.decorrelated && r.maxBackoff <= 0:
+		fmt.Fprintf(&b, ", decorrelated backoff from %s", first)
+	case r.decorrelated:
+		fmt.Fprintf(&b, ", decorrelated backoff %s..%s", first, last)
+	case r.exponential && r.attempts == Unlimited && r.maxBackoff <= 0:
+		fmt.Fprintf(&b, ", exponential backoff from %s", first)
+	case r.exponential:
+		fmt.Fprintf(&b, ", exponential backoff %s..%s", first, last)
+	case r.logarithmic && r.attempts == Unlimited && r.maxBackoff <= 0:
+		fmt.Fprintf(&b, ", logarithmic backoff from %s", first)
+	case r.logarithmic:
+		fmt.Fprintf(&b, ", logarithmic backoff %s..%s", first, last)
+	default:
+		fmt.Fprintf(&b, ", linear backoff %s", first)
+	}
+
+	jittered := !r.decorrelated && (r.jitter > 0 || r.jitterDuration > 0)
+	switch {
+	case ok && jittered && r.jitterDuration > 0:
+		fmt.Fprintf(&b, ", jitter ±%s", r.jitterDuration)
+	case ok && jittered:
+		fmt.Fprintf(&b, ", jitter %.4g%%", r.jitter*100)
+	}
+
+	if ok && r.exactFirstRetry && (jittered || r.decorrelated) {
+		b.WriteString(", exact first retry")
+	}
+
+	if ok && r.minBackoff > 0 {
+		fmt.Fprintf(&b, ", min backoff %s", r.minBackoff)
+	}
+
+	if r.alignment > 0 {
+		fmt.Fprintf(&b, ", aligned to %s", r.alignment)
+	}
+
+	if r.hours != nil {
+		fmt.Fprintf(&b, ", within hours %s..%s", r.hours.From, r.hours.To)
+	}
+
+	if r.attemptTimeout > 0 {
+		fmt.Fprintf(&b, ", attempt timeout %s", r.attemptTimeout)
+		if r.progressiveTimeout {
+			b.WriteString(" doubling")
+		}
+	}
+
+	if r.softDeadline > 0 {
+		fmt.Fprintf(&b, ", soft deadline %s", r.softDeadline)
+	}
+
+	if r.deadlineSpread {
+		b.WriteString(", deadline spread across attempts")
+	}
+
+	if r.pacer != nil {
+		fmt.Fprintf(&b, ", pacing %.4g retries/s", r.pacer.rate())
+	}
+
+	if window, n := r.window.limit(); n > 0 {
+		fmt.Fprintf(&b, ", at most %d retries per %s", n, window)
+	}
+
+	if r.thinned() {
+		fmt.Fprintf(&b, ", retry probability %.4g%%", r.probability*100)
+	}
+
+	for _, next := range r.next {
+		fmt.Fprintf(&b, "; then %s", next)
+	}
+
+	return b.String()
+}
+
+// policy is the JSON representation of Retry.
+type policy struct {
+	Attempts int     `json:"attempts"`
+	Backoff  string  `json:"backoff"`
+	Initial  string  `json:"initial,omitempty"`
+	Max      string  `json:"max,omitempty"`
+	Cap      string  `json:"cap,omitempty"`
+	Min      string  `json:"min,omitempty"`
+	Jitter   float64 `json:"jitter,omitempty"`
+
+	Multiplier         float64  `json:"multiplier,omitempty"`
+	JitterDuration     string   `json:"jitter_duration,omitempty"`
+	ExactFirstRetry    bool     `json:"exact_first_retry,omitempty"`
+	ImmediateRetries   int      `json:"immediate_retries,omitempty"`
+	AttemptTimeout     string   `json:"attempt_timeout,omitempty"`
+	ProgressiveTimeout bool     `json:"progressive_timeout,omitempty"`
+	SoftDeadline       string   `json:"soft_deadline,omitempty"`
+	DeadlineSpread     bool     `json:"deadline_spread,omitempty"`
+	RetryProbability   float64  `json:"retry_probability,omitempty"`
+	Alignment          string   `json:"alignment,omitempty"`
+	Pacing             float64  `json:"pacing,omitempty"`
+	MaxRetries         int      `json:"max_retries,omitempty"`
+	MaxRetriesWindow   string   `json:"max_retries_window,omitempty"`
+	Then               []policy `json:"then,omitempty"`
+}
+
+// MarshalJSON describes the effective policy of Retry, e.g.
+// {"attempts":5,"backoff":"exponential","initial":"100ms","max":"1.6s","cap":"0s","jitter":0.2}.
+// The max delay is the one after the last but one attempt,
+// it is omitted for unlimited attempts; the cap is MaxBackoff, zero if none.
+func (r Retry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.policy())
+}
+
+// policy returns the JSON representation of Retry
+func (r Retry) policy() policy {
+	p := policy{Attempts: r.attempts, Backoff: "none", ImmediateRetries: r.immediateRetries}
+
+	if first, last, ok := r.backoffRange(); ok {
+		p.Backoff, p.Initial, p.Max, p.Jitter = "linear", first.String(), last.String(), r.jitter
+		switch {
+		case r.decorrelated:
+			p.Backoff = "decorrelated"
+		case r.exponential:
+			p.Backoff, p.Multiplier = "exponential", r.multiplier
+		case r.logarithmic:
+			p.Backoff = "logarithmic"
+		}
+		if last <= 0 || r.attempts == Unlimited {
+			p.Max = ""
+		}
+		// the cap is set even if it is zero, so the max delay
+		// is not decoded as the cap, see Config.UnmarshalJSON
+		p.Cap = r.maxBackoff.String()
+		if r.minBackoff > 0 {
+			p.Min = r.minBackoff.String()
+		}
+		if r.jitterDuration > 0 && !r.decorrelated {
+			p.JitterDuration = r.jitterDuration.String()
+		}
+		p.ExactFirstRetry = r.exactFirstRetry
+	}
+
+	if r.attemptTimeout > 0 {
+		p.AttemptTimeout = r.attemptTimeout.String()
+		p.ProgressiveTimeout = r.progressiveTimeout
+	}
+	if r.softDeadline > 0 {
+		p.SoftDeadline = r.softDeadline.String()
+	}
+
+	p.DeadlineSpread = r.deadlineSpread
+
+	if r.thinned() {
+		p.RetryProbability = r.probability
+	}
+
+	if r.alignment > 0 {
+		p.Alignment = r.alignment.String()
+	}
+	p.Pacing = r.pacer.rate()
+	if window, n := r.window.limit(); n > 0 {
+		p.MaxRetries, p.MaxRetriesWindow = n, window.String()
+	}
+
+	for _, next := range r.next {
+		p.Then = append(p.Then, next.policy())
+	}
+	return p
+}
+
+// MaxAttempts returns the max number of Func calls of Do,
+// Unlimited for Forever policies.
+func (r Retry) MaxAttempts() int {
+	return r.attempts
+}
+
+// HasBackoff reports whether Do sleeps between attempts.
+func (r Retry) HasBackoff() bool {
+	return r.duration > 0
+}
+
+// BackoffAt returns the delay after zero-based failed attempt before jitter,
+// capped by MaxBackoff and floored by MinBackoff, zero for immediate retries.
+// Decorrelated backoff is random, the delay after the first attempt
+// is returned for it.
+func (r Retry) BackoffAt(attempt int) time.Duration {
+	if r.duration <= 0 || attempt < r.immediateRetries {
+		return 0
+	}
+	if r.decorrelated {
+		return r.capped(r.duration)
+	}
+	return r.capped(r.baseDelay(attempt - r.immediateRetries))
+}
+
+// BackoffRangeAt returns the bounds of the delay after zero-based failed
+// attempt including jitter, capped by MaxBackoff and floored by MinBackoff,
+// see BackoffAt.
+func (r Retry) BackoffRangeAt(attempt int) (min, max time.Duration) {
+	if r.duration <= 0 || attempt < r.immediateRetries {
+		return 0, 0
+	}
+	attempt -= r.immediateRetries
+
+	if r.decorrelated {
+		// the first delay is Backoff, the next ones are up to 3 times
+		// the previous one
+		max = r.capped(r.duration)
+		for i := 0; i < attempt; i++ {
+			max = r.decorrelatedDelay(max, 1)
+		}
+		return r.capped(r.duration), max
+	}
+
+	base := r.baseDelay(attempt)
+	switch {
+	case r.exactFirstRetry && attempt == 0:
+	case r.jitterDuration > 0:
+		return r.capped(backoff.JitteredBy(base, r.jitterDuration, 0)), r.capped(backoff.JitteredBy(base, r.jitterDuration, 1))
+	case r.jitter > 0:
+		return r.capped(backoff.Jittered(base, r.jitter, 0)), r.capped(backoff.Jittered(base, r.jitter, 1))
+	}
+	return r.capped(base), r.capped(base)
+}
+
+// backoffRange returns the delays (before jitter) after the first and
+// the last but one attempts, excluding immediate retries,
+// ok is false when Do never sleeps.
+func (r Retry) backoffRange() (first, last time.Duration, ok bool) {
+	if r.duration <= 0 || r.attempts < r.immediateRetries+2 {
+		return 0, 0, false
+	}
+
+	if r.decorrelated {
+		return r.duration, r.maxBackoff, true
+	}
+	return r.capped(r.baseDelay(0)), r.capped(r.baseDelay(r.attempts - r.immediateRetries - 2)), true
+}
+
+// UnmarshalJSON decodes Config from the JSON representation of Retry,
+// see Retry.MarshalJSON, e.g. fetched from a config service.
+// The cap delay caps the backoff, the min one floors it; the max one
+// caps the backoff only if the cap is absent, as written by older versions.
+// The fields present in the JSON are set even if they are zero, so the
+// decoded Config overrides them by Config.Merge, e.g. a partial policy
+// fetched from a config service. Settings the JSON can't carry are left
+// zero, e.g. classifiers, hooks, recorders, limiters, clocks, hours,
+// Switch routes and class limits, see FromConfig to add them.
+func (cfg *Config) UnmarshalJSON(data []byte) error {
+	var (
+		p      policy
+		fields map[string]json.RawMessage
+	)
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	if p.Cap == "" {
+		p.Cap = p.Max
+	}
+	decoded, err := p.config()
+	if err != nil {
+		return err
+	}
+	for name, set := range policyFields {
+		if _, ok := fields[name]; ok {
+			decoded.set |= set
+		}
+	}
+	if p.Backoff == "none" {
+		decoded.set |= setBackoff
+	}
+	*cfg = decoded
+	return nil
+}
+
+// policyFields are the fields of Config set by the fields of the JSON
+// representation of Retry
+var policyFields = map[string]fields{
+	"attempts":            setAttempts,
+	"backoff":             setExponential | setLogarithmic | setDecorrelated,
+	"initial":             setBackoff,
+	"max":                 setMaxBackoff,
+	"cap":                 setMaxBackoff,
+	"min":                 setMinBackoff,
+	"jitter":              setJitter,
+	"multiplier":          setMultiplier,
+	"jitter_duration":     setJitterDuration,
+	"exact_first_retry":   setExactFirstRetry,
+	"immediate_retries":   setImmediateRetries,
+	"attempt_timeout":     setAttemptTimeout,
+	"progressive_timeout": setProgressiveTimeout,
+	"soft_deadline":       setSoftDeadline,
+	"deadline_spread":     setDeadlineSpread,
+	"retry_probability":   setRetryProbability,
+	"alignment":           setAlignment,
+	"pacing":              setPacing,
+	"max_retries":         setMaxRetries,
+	"max_retries_window":  setMaxRetries,
+	"then":                setThen,
+}
+
+// config returns Config of the JSON representation of Retry
+func (p policy) config() (Config, error) {
+	cfg := Config{
+		Attempts:           p.Attempts,
+		ImmediateRetries:   p.ImmediateRetries,
+		ExactFirstRetry:    p.ExactFirstRetry,
+		Jitter:             p.Jitter,
+		Multiplier:         p.Multiplier,
+		ProgressiveTimeout: p.ProgressiveTimeout,
+		DeadlineSpread:     p.DeadlineSpread,
+		RetryProbability:   p.RetryProbability,
+		Pacing:             p.Pacing,
+		MaxRetries:         p.MaxRetries,
+	}
+
+	switch p.Backoff {
+	case "", "none", "linear":
+	case "exponential":
+		cfg.Exponential = true
+	case "logarithmic":
+		cfg.Logarithmic = true
+	case "decorrelated":
+		cfg.Decorrelated = true
+	default:
+		return Config{}, fmt.Errorf("retry: unknown backoff %q", p.Backoff)
+	}
+
+	for _, field := range []struct {
+		value string
+		dst   *time.Duration
+	}{
+		{p.Initial, &cfg.Backoff},
+		{p.Cap, &cfg.MaxBackoff},
+		{p.Min, &cfg.MinBackoff},
+		{p.JitterDuration, &cfg.JitterDuration},
+		{p.AttemptTimeout, &cfg.AttemptTimeout},
+		{p.SoftDeadline, &cfg.SoftDeadline},
+		{p.Alignment, &cfg.Alignment},
+		{p.MaxRetriesWindow, &cfg.MaxRetriesWindow},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil {
+			return Config{}, fmt.Errorf("retry: %w", err)
+		}
+		*field.dst = d
+	}
+
+	for _, then := range p.Then {
+		next, err := then.config()
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Then = append(cfg.Then, New(next))
+	}
+	return cfg, nil
+}
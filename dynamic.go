@@ -0,0 +1,84 @@
+package retry
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// DynamicRetry is a policy replaceable at runtime, e.g. by a file watcher
+// or a config service: Do calls in flight pick up the new policy on
+// their next attempt, continuing their backoff state.
+// DynamicRetry is safe for concurrent use.
+type DynamicRetry struct {
+	policy atomic.Value // *Retry
+}
+
+// NewDynamicRetry returns DynamicRetry with the initial policy.
+func NewDynamicRetry(policy Retry) *DynamicRetry {
+	d := new(DynamicRetry)
+	d.Store(policy)
+	return d
+}
+
+// Store replaces the policy.
+func (d *DynamicRetry) Store(policy Retry) {
+	policy.dynamic = nil
+	d.policy.Store(&policy)
+}
+
+// StoreConfig replaces the policy by the one of cfg, if it is valid,
+// see Config.Validate.
+func (d *DynamicRetry) StoreConfig(cfg Config) error {
+	policy, err := NewStrict(cfg)
+	if err != nil {
+		return err
+	}
+	d.Store(policy)
+	return nil
+}
+
+// Load returns the current policy.
+func (d *DynamicRetry) Load() Retry {
+	return *d.load()
+}
+
+// PolicyFor returns the current policy, see PolicyProvider.
+func (d *DynamicRetry) PolicyFor(context.Context) Retry {
+	return d.Load()
+}
+
+// Do works same as Retry.Do with the current policy,
+// reloading it before each attempt.
+func (d *DynamicRetry) Do(ctx context.Context, call Func) error {
+	r := d.Load()
+	r.dynamic = d
+	return r.Do(ctx, call)
+}
+
+// DoContext works same as Retry.DoContext with the current policy,
+// reloading it before each attempt.
+func (d *DynamicRetry) DoContext(ctx context.Context, call ContextFunc) error {
+	r := d.Load()
+	r.dynamic = d
+	return r.DoContext(ctx, call)
+}
+
+func (d *DynamicRetry) load() *Retry {
+	return d.policy.Load().(*Retry)
+}
+
+// reload replaces Retry by the current policy of DynamicRetry
+// if it is not the loaded one, reports whether it is replaced
+func (r *Retry) reload(ctx context.Context, loaded **Retry) bool {
+	current := r.dynamic.load()
+	if current == *loaded {
+		return false
+	}
+
+	*loaded = current
+	dynamic := r.dynamic
+	*r = *current
+	r.dynamic = dynamic
+	r.override(ctx)
+	return true
+}
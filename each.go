@@ -0,0 +1,75 @@
+package retry
+
+import (
+	"context"
+	"sync"
+)
+
+// EachOption configures ForEach.
+type EachOption func(*each)
+
+// each is the configuration of ForEach
+type each struct {
+	parallelism int
+}
+
+// WithParallelism limits the number of items processed by ForEach
+// concurrently, 1 by default.
+func WithParallelism(n int) EachOption {
+	return func(e *each) {
+		e.parallelism = n
+	}
+}
+
+// ForEach calls fn for every item, retrying each item independently
+// with the policy, see Retry.DoContext. Errors are classified by WithRetryIf,
+// every error is retried by default. ForEach returns the final error
+// of every item in the order of items, nil if all items succeeded.
+func ForEach[T any](ctx context.Context, r Retry, items []T, fn func(ctx context.Context, item T) error, opts ...EachOption) []error {
+	cfg := each{parallelism: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.parallelism < 1 {
+		cfg.parallelism = 1
+	}
+	if cfg.parallelism > len(items) {
+		cfg.parallelism = len(items)
+	}
+
+	var (
+		errs   = make([]error, len(items))
+		failed bool
+		mu     sync.Mutex
+		next   = make(chan int)
+		wg     sync.WaitGroup
+	)
+	for w := 0; w < cfg.parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				item := items[i]
+				err := r.DoContext(ctx, Ctx(func(ctx context.Context) error {
+					return fn(ctx, item)
+				}, r.retryIf))
+				if err != nil {
+					mu.Lock()
+					errs[i], failed = err, true
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range items {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	if !failed {
+		return nil
+	}
+	return errs
+}
@@ -0,0 +1,76 @@
+package retry
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// FileErrors are errors of file I/O and exec that are temporary:
+// interrupted system calls, resources temporarily unavailable,
+// and files busy or locked by another process. The errors are specific
+// to the platform, e.g. sharing violations on Windows.
+var FileErrors = fileErrors
+
+// OnFileErrors returns Classifier retrying errors with one of FileErrors
+// in the chain, e.g. *os.PathError of os.Open or os.Rename.
+func OnFileErrors() Classifier {
+	return OnErrors(FileErrors...)
+}
+
+// ConnErrors are errors of network connections closed or refused
+// by the peer, usually temporary, e.g. when the peer restarts.
+// The errors are specific to the platform, e.g. WSAECONNRESET on Windows.
+var ConnErrors = connErrors
+
+// connMessages are messages of ConnErrors, matched in errors
+// whose chain is lost, e.g. flattened to a gRPC status message
+var connMessages = []string{
+	"connection reset by peer",
+	"connection refused",
+	"broken pipe",
+	"forcibly closed by the remote host",
+}
+
+// OnConnReset returns Classifier retrying errors with one of ConnErrors
+// in the chain, e.g. *net.OpError of HTTP, gRPC or raw TCP clients.
+// Errors without syscall errors in the chain are matched by the message,
+// e.g. "write: broken pipe".
+func OnConnReset() Classifier {
+	errs := OnErrors(ConnErrors...)
+	return func(err error) bool {
+		if errs(err) {
+			return true
+		}
+		msg := err.Error()
+		for _, connMsg := range connMessages {
+			if strings.Contains(msg, connMsg) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// OnErrors returns Classifier retrying errors matching one of targets
+// by errors.Is, e.g. syscall errors.
+func OnErrors(targets ...error) Classifier {
+	return func(err error) bool {
+		for _, target := range targets {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FileIO initializes Retry of file I/O and exec, e.g. of a file locked
+// for a moment by an antivirus or a backup: up to 5 attempts with the
+// exponential backoff from 10ms, retrying FileErrors only.
+func FileIO() Retry {
+	return Attempts(5).
+		ExponentialJitterBackoff(10*time.Millisecond, DefaultJitter).
+		MaxBackoff(time.Second).
+		RetryIf(OnFileErrors())
+}
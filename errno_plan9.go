@@ -0,0 +1,11 @@
+package retry
+
+import "syscall"
+
+var fileErrors = []error{
+	syscall.EINTR,
+	syscall.EBUSY,
+}
+
+// connErrors is empty, Plan 9 reports network errors by messages only
+var connErrors []error
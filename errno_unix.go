@@ -0,0 +1,18 @@
+//go:build !windows && !plan9
+
+package retry
+
+import "syscall"
+
+var fileErrors = []error{
+	syscall.EINTR,
+	syscall.EAGAIN,
+	syscall.EBUSY,
+}
+
+var connErrors = []error{
+	syscall.ECONNRESET,
+	syscall.ECONNREFUSED,
+	syscall.ECONNABORTED,
+	syscall.EPIPE,
+}
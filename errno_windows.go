@@ -0,0 +1,25 @@
+package retry
+
+import "syscall"
+
+// Windows system error codes missing in syscall,
+// see https://learn.microsoft.com/windows/win32/debug/system-error-codes
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+	wsaEConnRefused       syscall.Errno = 10061
+)
+
+var fileErrors = []error{
+	// files opened by another process, e.g. an antivirus or a backup
+	errorSharingViolation,
+	errorLockViolation,
+}
+
+var connErrors = []error{
+	syscall.WSAECONNRESET,
+	syscall.WSAECONNABORTED,
+	wsaEConnRefused,
+	syscall.ERROR_BROKEN_PIPE,
+	syscall.ERROR_NETNAME_DELETED,
+}
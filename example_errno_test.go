@@ -0,0 +1,53 @@
+//go:build !windows && !plan9
+
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/osvim/retry"
+)
+
+func ExampleFileIO() {
+	var i int
+
+	rename := func() error {
+		i++
+		if i < 3 {
+			// the file is locked by another process for a moment
+			return &os.LinkError{Op: "rename", Old: "report.tmp", New: "report.csv", Err: syscall.EBUSY}
+		}
+		return &os.LinkError{Op: "rename", Old: "report.tmp", New: "report.csv", Err: syscall.EACCES}
+	}
+
+	err := retry.FileIO().Do(context.TODO(), retry.Classify(rename, retry.OnFileErrors()))
+
+	fmt.Println(err, i)
+	// Output: rename report.tmp report.csv: permission denied 3
+}
+
+func ExampleOnConnReset() {
+	var i int
+
+	query := func() error {
+		i++
+		switch i {
+		case 1:
+			return &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+		case 2:
+			// the error of a remote service, its chain is lost
+			return errors.New("rpc error: code = Unavailable desc = write: broken pipe")
+		}
+		return errors.New("rpc error: code = NotFound desc = no such user")
+	}
+
+	err := retry.Attempts(5).Do(context.TODO(), retry.Classify(query, retry.OnConnReset()))
+
+	fmt.Println(err, i)
+	// Output: rpc error: code = NotFound desc = no such user 3
+}
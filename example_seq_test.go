@@ -0,0 +1,23 @@
+//go:build go1.23
+
+package retry_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/osvim/retry"
+	"github.com/osvim/retry/backoff"
+)
+
+func ExampleBackoff_Seq() {
+	b := retry.Backoff(backoff.Exponential(100 * time.Millisecond))
+
+	for delay := range b.Seq(4) {
+		fmt.Println(delay)
+	}
+	// Output:
+	// 100ms
+	// 200ms
+	// 400ms
+}
@@ -2,10 +2,17 @@ package retry_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/osvim/retry"
+	"github.com/osvim/retry/backoff"
 )
 
 func ExampleDo() {
@@ -67,3 +74,1864 @@ func ExampleRetry_Do() {
 	fmt.Println(err)
 	// Output: no attempts left: needs 3 attempts
 }
+
+func ExampleRetry_String() {
+	policy := retry.Attempts(5).ExponentialJitterBackoff(100*time.Millisecond, 0.2)
+
+	fmt.Println(policy)
+	// Output: 5 attempts, exponential backoff 100ms..800ms, jitter 20%
+}
+
+func ExampleRetry_MarshalJSON() {
+	policy := retry.Attempts(3).Backoff(time.Second)
+
+	data, _ := json.Marshal(policy)
+
+	fmt.Println(string(data))
+	// Output: {"attempts":3,"backoff":"linear","initial":"1s","max":"1s","cap":"0s"}
+}
+
+func ExampleRetry_Multiplier() {
+	policy := retry.Attempts(4).ExponentialBackoff(100 * time.Millisecond).Multiplier(1.5)
+
+	for attempt := 0; attempt < 3; attempt++ {
+		fmt.Println(policy.BackoffAt(attempt))
+	}
+	// Output:
+	// 100ms
+	// 150ms
+	// 225ms
+}
+
+func ExampleConfig_Merge() {
+	base := retry.Config{
+		Attempts:    5,
+		Backoff:     100 * time.Millisecond,
+		Exponential: true,
+		Jitter:      0.2,
+	}
+
+	// zero Attempts is inherited from base, zero Jitter is set explicitly
+	override := retry.Config{Backoff: time.Second}.With(retry.WithJitter(0))
+
+	fmt.Println(retry.New(base.Merge(override)))
+	// Output: 5 attempts, exponential backoff 1s..8s
+}
+
+func ExampleConfig_Merge_classes() {
+	classOf := func(err error) retry.Class {
+		var apiErr apiError
+		if errors.As(err, &apiErr) {
+			return retry.Class(apiErr.ErrorCode())
+		}
+		return ""
+	}
+	base := retry.Config{}.With(
+		retry.WithAttempts(5),
+		retry.WithAttemptsByClass(classOf, map[retry.Class]int{"Throttling": 2}),
+	)
+
+	// the routes of the override keep the limits of base
+	override := retry.Config{}.With(
+		retry.WithSwitch(classOf, map[retry.Class]retry.Retry{"Unavailable": retry.Attempts(3)}),
+	)
+
+	merged := base.Merge(override)
+	fmt.Println(merged.AttemptsByClass, len(merged.Policies))
+	// Output: map[Throttling:2] 1
+}
+
+func ExampleSetGlobalBudget() {
+	retry.SetGlobalBudget(1)
+	defer retry.SetGlobalBudget(0)
+
+	call := func() (repeat bool, err error) {
+		return true, fmt.Errorf("unavailable")
+	}
+
+	// the first retry consumes the budget, the second one is rejected
+	err := retry.Attempts(5).Do(context.TODO(), call)
+
+	fmt.Println(errors.Is(err, retry.ErrBudgetExceeded), err)
+	// Output: true retry budget exceeded: unavailable
+}
+
+func ExampleNewBudget() {
+	// a retry every two seconds
+	budget := retry.NewBudget(0.5)
+
+	fmt.Println(budget.Allow(), budget.Allow())
+	// Output: true false
+}
+
+func ExampleWithRetryProbability() {
+	var calls int
+
+	err := retry.Do(
+		context.TODO(),
+		func() (repeat bool, err error) {
+			calls++
+			return true, fmt.Errorf("overloaded")
+		},
+		retry.WithAttempts(1000),
+		retry.WithRetryProbability(0.5),
+	)
+
+	// about a half of failures is retried
+	fmt.Println(err, calls < 1000)
+	// Output: overloaded true
+}
+
+func ExampleSimulate() {
+	policy := retry.Attempts(3).Backoff(100 * time.Millisecond)
+
+	sim := retry.Simulate(policy, retry.FailureRate(1, 10*time.Millisecond))
+
+	fmt.Println(sim.Succeeded, sim.Latency.Worst, sim.Attempts.Worst)
+	// Output: 0 230ms 3
+}
+
+func ExampleSimulate_then() {
+	policy := retry.Attempts(2).Backoff(10 * time.Millisecond).
+		Then(retry.Attempts(2).Backoff(100 * time.Millisecond))
+
+	sim := retry.Simulate(policy, retry.FailureRate(1, 0))
+
+	fmt.Println(sim.Latency.Worst, sim.Attempts.Worst)
+	// Output: 210ms 4
+}
+
+func ExampleSimulate_forever() {
+	sim := retry.Simulate(retry.Forever().Backoff(time.Millisecond), retry.FailureRate(1, 0))
+
+	fmt.Println(sim.Succeeded, sim.Latency.Worst, sim.Attempts.Worst)
+	// Output: 0 999ms 1000
+}
+
+func ExampleSimulateErrors() {
+	errThrottled := errors.New("throttled")
+	classOf := func(err error) retry.Class {
+		if errors.Is(err, errThrottled) {
+			return "throttled"
+		}
+		return ""
+	}
+	policy := retry.Switch(classOf, map[retry.Class]retry.Retry{
+		"throttled": retry.Attempts(3).Backoff(time.Second),
+	}, retry.Attempts(2).Backoff(10*time.Millisecond))
+
+	sim := retry.SimulateErrors(policy, func(attempt int, rnd *rand.Rand) (time.Duration, error) {
+		return 0, errThrottled
+	})
+
+	fmt.Println(sim.Latency.Worst, sim.Attempts.Worst)
+	// Output: 2s 3
+}
+
+func ExampleDoTyped() {
+	var i int
+
+	permanent := errors.New("permanent")
+
+	value, err := retry.DoTyped(
+		context.TODO(),
+		func(ctx context.Context) (int, error) {
+			i++
+			if i < 3 {
+				return 0, fmt.Errorf("needs 3 attempts")
+			}
+			return i, permanent
+		},
+		retry.WithAttempts(5),
+		retry.WithRetryIf(func(err error) bool {
+			return !errors.Is(err, permanent)
+		}),
+	)
+
+	fmt.Println(value, err)
+	// Output: 3 permanent
+}
+
+func ExampleDo2() {
+	var i int
+
+	items, cursor, err := retry.Do2(
+		context.TODO(),
+		func(ctx context.Context) ([]string, string, error) {
+			i++
+			if i < 2 {
+				return nil, "", fmt.Errorf("needs 2 attempts")
+			}
+			return []string{"a", "b"}, "next", nil
+		},
+		retry.WithAttempts(3),
+	)
+
+	fmt.Println(items, cursor, err)
+	// Output: [a b] next <nil>
+}
+
+func ExampleClassify() {
+	var i int
+
+	errNotFound := errors.New("not found")
+
+	fetch := func() error {
+		i++
+		if i < 2 {
+			return fmt.Errorf("timeout")
+		}
+		return errNotFound
+	}
+
+	err := retry.Attempts(5).Do(context.TODO(), retry.Classify(fetch, func(err error) bool {
+		return !errors.Is(err, errNotFound)
+	}))
+
+	fmt.Println(err, i)
+	// Output: not found 2
+}
+
+func ExampleCtx() {
+	var i int
+
+	fetch := func(ctx context.Context) error {
+		i++
+		if i < 2 {
+			// the first call hangs until the attempt timeout
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+
+	err := retry.DoContext(
+		context.TODO(),
+		retry.Ctx(fetch, nil),
+		retry.WithAttempts(3),
+		retry.WithAttemptTimeout(10*time.Millisecond),
+	)
+
+	fmt.Println(err, i)
+	// Output: <nil> 2
+}
+
+func ExampleRetryable() {
+	var i int
+
+	query := func(ctx context.Context) error {
+		i++
+		if i < 2 {
+			return retry.Retryable(fmt.Errorf("replica: %w", context.DeadlineExceeded))
+		}
+		return nil
+	}
+
+	err := retry.DoContext(
+		context.TODO(),
+		retry.Ctx(query, func(err error) bool {
+			return !errors.Is(err, context.DeadlineExceeded)
+		}),
+		retry.WithAttempts(3),
+	)
+
+	fmt.Println(err, i)
+	// Output: <nil> 2
+}
+
+func ExampleForever() {
+	var i int
+
+	err := retry.Forever().Backoff(time.Millisecond).Do(context.TODO(), func() (repeat bool, err error) {
+		i++
+		if i == 5 {
+			return false, retry.Abort(fmt.Errorf("gave up after %d calls", i))
+		}
+		return true, fmt.Errorf("unavailable")
+	})
+
+	fmt.Println(err)
+	// Output: gave up after 5 calls
+}
+
+func ExampleAbort() {
+	var i int
+
+	err := retry.Forever().Backoff(time.Millisecond).Do(context.TODO(), func() (repeat bool, err error) {
+		i++
+		if i == 3 {
+			return false, retry.Abort(nil)
+		}
+		return true, fmt.Errorf("unavailable")
+	})
+
+	fmt.Println(err, errors.Is(err, retry.ErrAborted), i)
+	// Output: retry: aborted true 3
+}
+
+func ExampleWithDeadlineSpread() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var timeouts []time.Duration
+
+	_ = retry.DoContext(
+		ctx,
+		func(ctx context.Context) (bool, error) {
+			deadline, _ := ctx.Deadline()
+			timeouts = append(timeouts, time.Until(deadline).Round(10*time.Millisecond))
+			return true, fmt.Errorf("unavailable")
+		},
+		retry.WithAttempts(4),
+		retry.WithDeadlineSpread(),
+	)
+
+	fmt.Println(timeouts)
+	// Output: [250ms 330ms 500ms 1s]
+}
+
+func ExampleRetry_DeadlineSpread_then() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var timeouts []time.Duration
+
+	// the deadline is spread across the attempts of each stage
+	_ = retry.Attempts(2).DeadlineSpread().Then(retry.Attempts(3)).DoContext(
+		ctx,
+		func(ctx context.Context) (bool, error) {
+			deadline, _ := ctx.Deadline()
+			timeouts = append(timeouts, time.Until(deadline).Round(10*time.Millisecond))
+			return true, fmt.Errorf("unavailable")
+		},
+	)
+
+	fmt.Println(timeouts)
+	// Output: [500ms 1s 330ms 500ms 1s]
+}
+
+// apiError mimics an error of AWS SDK
+type apiError struct {
+	code string
+}
+
+func (e apiError) Error() string     { return "api error " + e.code }
+func (e apiError) ErrorCode() string { return e.code }
+
+func ExampleOnThrottling() {
+	var i int
+
+	put := func() error {
+		i++
+		if i < 3 {
+			return fmt.Errorf("put item: %w", apiError{code: "ProvisionedThroughputExceededException"})
+		}
+		return apiError{code: "AccessDeniedException"}
+	}
+
+	err := retry.Attempts(5).Do(context.TODO(), retry.Classify(put, retry.OnThrottling()))
+
+	fmt.Println(err, i)
+	// Output: api error AccessDeniedException 3
+}
+
+func ExampleOnErrorCode() {
+	throttled := retry.OnErrorCode("Throttling", "rateLimitExceeded")
+
+	fmt.Println(throttled(apiError{code: "Throttling"}))
+	fmt.Println(throttled(apiError{code: "throttling"}))
+	fmt.Println(throttled(errors.New("googleapi: Error 403: Quota exceeded, rateLimitExceeded")))
+	fmt.Println(throttled(errors.New("throttling is disabled for the table")))
+	// Output:
+	// true
+	// false
+	// true
+	// false
+}
+
+func ExampleWithRateLimiter() {
+	// the limiter is shared by all calls to the service
+	limiter := retry.NewAdaptiveRateLimiter()
+
+	var i int
+
+	err := retry.Do(
+		context.TODO(),
+		retry.Always(func() error {
+			i++
+			if i < 2 {
+				// throttling errors would slow down attempts of all calls sharing the limiter
+				return apiError{code: "InternalError"}
+			}
+			return nil
+		}),
+		retry.WithAttempts(3),
+		retry.WithRateLimiter(limiter, retry.OnThrottling()),
+	)
+
+	fmt.Println(err, i)
+	// Output: <nil> 2
+}
+
+func ExampleRetry_NewSession() {
+	policy := retry.Attempts(2).ExponentialBackoff(time.Millisecond).MaxBackoff(time.Second)
+
+	// reconnect loop keeps escalating backoff across Do calls
+	session := policy.NewSession()
+	for i := 0; i < 3; i++ {
+		err := session.Do(context.TODO(), func() (repeat bool, err error) {
+			return true, fmt.Errorf("connection refused")
+		})
+		fmt.Println(err)
+	}
+	// Output:
+	// no attempts left: connection refused
+	// no attempts left: connection refused
+	// no attempts left: connection refused
+}
+
+func ExampleRetry_DecorrelatedBackoff() {
+	policy := retry.Attempts(5).DecorrelatedBackoff(100 * time.Millisecond).MaxBackoff(5 * time.Second)
+
+	fmt.Println(policy)
+	// Output: 5 attempts, decorrelated backoff 100ms..5s
+}
+
+func ExampleWithExplain() {
+	var i int
+
+	_ = retry.Do(
+		context.TODO(),
+		func() (repeat bool, err error) {
+			i++
+			if i < 3 {
+				return true, fmt.Errorf("connection refused")
+			}
+			return false, fmt.Errorf("access denied")
+		},
+		retry.WithAttempts(5),
+		retry.WithBackoff(time.Millisecond),
+		retry.WithExponential(),
+		retry.WithExplain(os.Stdout),
+	)
+
+	// Output:
+	// attempt 1 failed: connection refused; retryable, sleeping 1ms
+	// attempt 2 failed: connection refused; retryable, sleeping 2ms
+	// attempt 3 failed: access denied; permanent, giving up
+}
+
+func ExampleDisableRetries() {
+	ctx := retry.DisableRetries(context.TODO())
+
+	var calls int
+
+	err := retry.Attempts(5).Do(ctx, func() (repeat bool, err error) {
+		calls++
+		return true, fmt.Errorf("unavailable")
+	})
+
+	fmt.Println(err, calls)
+	// Output: no attempts left: unavailable 1
+}
+
+func ExampleNone() {
+	var calls int
+
+	err := retry.None().Do(context.TODO(), func() (repeat bool, err error) {
+		calls++
+		return true, fmt.Errorf("unavailable")
+	})
+
+	fmt.Println(retry.None(), "|", err, calls)
+	// Output: 1 attempt, no backoff | no attempts left: unavailable 1
+}
+
+func ExampleRetry_With() {
+	base := retry.Attempts(3).ExponentialJitterBackoff(100*time.Millisecond, 0.2)
+
+	critical := base.With(retry.WithAttempts(5), retry.WithMaxBackoff(time.Second))
+
+	fmt.Println(base)
+	fmt.Println(critical)
+	// Output:
+	// 3 attempts, exponential backoff 100ms..200ms, jitter 20%
+	// 5 attempts, exponential backoff 100ms..800ms, jitter 20%
+}
+
+func ExampleNewStrict() {
+	_, err := retry.NewStrict(retry.Config{}.With(
+		retry.WithAttempts(3),
+		retry.WithBackoff(time.Second),
+		retry.WithJitter(1.5),
+	))
+
+	fmt.Println(err)
+	// Output: invalid retry config: jitter 1.5 out of range [0.0, 1.0)
+}
+
+func ExampleWithSwitch() {
+	byCode := func(err error) retry.Class { return retry.Class(err.Error()) }
+	all := func(err error) retry.Class { return "any" }
+
+	// the classifiers of the class limits and the routes are separate
+	var calls int
+	err := retry.Do(context.TODO(), func() (bool, error) {
+		calls++
+		return true, errors.New("Throttling")
+	},
+		retry.WithAttempts(10),
+		retry.WithAttemptsByClass(byCode, map[retry.Class]int{"Throttling": 1}),
+		retry.WithSwitch(all, map[retry.Class]retry.Retry{"any": retry.Attempts(5)}),
+	)
+	fmt.Println(err, calls)
+
+	_, err = retry.NewStrict(retry.Config{}.With(
+		retry.WithSwitch(nil, map[retry.Class]retry.Retry{"any": retry.Attempts(5)}),
+	))
+	fmt.Println(err)
+	// Output:
+	// no attempts left: Throttling 2
+	// invalid retry config: attempts 0 must be positive; switch policies are set without class classifier
+}
+
+// logRecorder prints attempts
+type logRecorder struct {
+	name string
+}
+
+func (l logRecorder) AttemptStarted(ctx context.Context, attempt int) {}
+
+func (l logRecorder) AttemptEnded(ctx context.Context, attempt int, err error, duration time.Duration) {
+	fmt.Printf("%s: attempt %d ended: %v\n", l.name, attempt, err)
+}
+
+func (l logRecorder) Exhausted(ctx context.Context, attempts int, err error) {
+	fmt.Printf("%s: exhausted after %d attempts: %v\n", l.name, attempts, err)
+}
+
+func ExampleWithRecorder() {
+	_ = retry.Do(
+		context.TODO(),
+		func() (repeat bool, err error) {
+			return true, fmt.Errorf("unavailable")
+		},
+		retry.WithAttempts(2),
+		retry.WithRecorder(logRecorder{name: "metrics"}, logRecorder{name: "log"}),
+	)
+
+	// Output:
+	// metrics: attempt 0 ended: unavailable
+	// log: attempt 0 ended: unavailable
+	// metrics: attempt 1 ended: unavailable
+	// log: attempt 1 ended: unavailable
+	// metrics: exhausted after 2 attempts: unavailable
+	// log: exhausted after 2 attempts: unavailable
+}
+
+func ExampleWithHeartbeat() {
+	var i int
+
+	_ = retry.Do(
+		context.TODO(),
+		func() (repeat bool, err error) {
+			i++
+			return i < 2, fmt.Errorf("unavailable")
+		},
+		retry.WithAttempts(2),
+		retry.WithBackoff(35*time.Millisecond),
+		retry.WithHeartbeat(10*time.Millisecond, func(remaining time.Duration) {
+			fmt.Println("sleeping, remaining", remaining)
+		}),
+	)
+
+	// Output:
+	// sleeping, remaining 25ms
+	// sleeping, remaining 15ms
+	// sleeping, remaining 5ms
+}
+
+func ExampleWithWake() {
+	wake := make(chan struct{}, 1)
+
+	var i int
+	started := time.Now()
+
+	err := retry.Do(
+		context.TODO(),
+		func() (repeat bool, err error) {
+			i++
+			if i < 2 {
+				// e.g. dependency reports it is healthy again
+				wake <- struct{}{}
+				return true, fmt.Errorf("unavailable")
+			}
+			return false, nil
+		},
+		retry.WithAttempts(2),
+		retry.WithBackoff(time.Minute),
+		retry.WithWake(wake),
+	)
+
+	fmt.Println(err, time.Since(started) < time.Minute)
+	// Output: <nil> true
+}
+
+func ExampleRetry_Start() {
+	var i int
+
+	controller := retry.Forever().Backoff(time.Hour).Start(context.TODO(), func() (repeat bool, err error) {
+		i++
+		if i < 2 {
+			return true, fmt.Errorf("connection refused")
+		}
+		return false, nil
+	})
+
+	// wait for the first attempt to fail and nudge the loop
+	for controller.Status().Attempts < 1 || controller.Status().InAttempt {
+		time.Sleep(time.Millisecond)
+	}
+	controller.RetryNow()
+
+	err := controller.Wait()
+	fmt.Println(err, controller.Status().Attempts)
+	// Output: <nil> 2
+}
+
+func ExampleRetry_Pacing() {
+	// at most 100 retries per second of all calls sharing the policy
+	policy := retry.Attempts(2).Pacing(100)
+
+	started := time.Now()
+	for i := 0; i < 5; i++ {
+		_ = policy.Do(context.TODO(), func() (repeat bool, err error) {
+			return true, fmt.Errorf("unavailable")
+		})
+	}
+
+	fmt.Println(policy, "|", time.Since(started) >= 40*time.Millisecond)
+	// Output: 2 attempts, no backoff, pacing 100 retries/s | true
+}
+
+func ExampleRetry_Pacing_softDeadline() {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	failing := func() (bool, error) { return true, errors.New("unavailable") }
+
+	// one retry per second of all calls sharing the policy
+	paced := retry.Attempts(2).Pacing(1).Clock(clock)
+	hasty := paced.SoftDeadline(100 * time.Millisecond)
+
+	_ = paced.Do(context.TODO(), failing)
+
+	// the retry would start past the soft deadline, so its slot is left free
+	_ = hasty.Do(context.TODO(), failing)
+
+	started := clock.Now()
+	_ = paced.Do(context.TODO(), failing)
+	fmt.Println(clock.Since(started))
+	// Output: 1s
+}
+
+func ExampleWithOnExhausted() {
+	err := retry.Do(
+		context.TODO(),
+		func() (repeat bool, err error) {
+			return true, fmt.Errorf("unavailable")
+		},
+		retry.WithAttempts(3),
+		retry.WithOnExhausted(func(ctx context.Context, attempts int, lastErr error) {
+			fmt.Printf("alert: gave up after %d attempts: %v\n", attempts, lastErr)
+		}),
+	)
+
+	// the caller swallows the error
+	_ = err
+
+	// Output: alert: gave up after 3 attempts: unavailable
+}
+
+func ExampleWithOnRetryableFailure() {
+	conns := []string{"conn-1", "conn-2"}
+	var i int
+
+	err := retry.Do(
+		context.TODO(),
+		func() (repeat bool, err error) {
+			conn := conns[i]
+			i++
+			if conn == "conn-1" {
+				return true, retry.FailedOn(fmt.Errorf("broken pipe"), conn)
+			}
+			return false, nil
+		},
+		retry.WithAttempts(2),
+		retry.WithOnRetryableFailure(func(err error, resource interface{}) {
+			fmt.Printf("evict %v: %v\n", resource, err)
+		}),
+	)
+
+	fmt.Println(err)
+	// Output:
+	// evict conn-1: broken pipe
+	// <nil>
+}
+
+func ExampleForEach() {
+	var (
+		mu    sync.Mutex
+		calls = map[string]int{}
+	)
+
+	errs := retry.ForEach(context.TODO(), retry.Attempts(3), []string{"a", "b", "c"},
+		func(ctx context.Context, item string) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			calls[item]++
+			if item == "b" && calls[item] < 2 {
+				return errors.New("temporary")
+			}
+			if item == "c" {
+				return retry.Abort(errors.New("permanent"))
+			}
+			return nil
+		},
+		retry.WithParallelism(2),
+	)
+
+	fmt.Println(errs, calls)
+	// Output: [<nil> <nil> permanent] map[a:1 b:2 c:1]
+}
+
+func ExampleStage() {
+	var calls int
+	parse := retry.Stage(retry.Attempts(3), func(ctx context.Context, in string) (int, error) {
+		calls++
+		if calls == 1 {
+			return 0, errors.New("temporary")
+		}
+		return len(in), nil
+	}, nil)
+
+	in, out := make(chan string), make(chan int)
+	go func() {
+		defer close(out)
+		for s := range in {
+			n, err := parse(context.TODO(), s)
+			if err != nil {
+				continue
+			}
+			out <- n
+		}
+	}()
+	go func() {
+		defer close(in)
+		in <- "go"
+		in <- "retry"
+	}()
+
+	for n := range out {
+		fmt.Println(n)
+	}
+	// Output:
+	// 2
+	// 5
+}
+
+// fakeClock fires timers immediately, advancing its time
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Since(t time.Time) time.Duration { return c.now.Sub(t) }
+
+func (c *fakeClock) NewTimer(d time.Duration) retry.Timer {
+	c.now = c.now.Add(d)
+	fired := make(chan time.Time, 1)
+	fired <- c.now
+	return fakeTimer(fired)
+}
+
+type fakeTimer chan time.Time
+
+func (t fakeTimer) C() <-chan time.Time { return t }
+
+func (t fakeTimer) Stop() bool { return false }
+
+func ExampleWithClock() {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	started := clock.Now()
+
+	err := retry.Do(context.TODO(), func() (bool, error) {
+		return true, errors.New("unavailable")
+	},
+		retry.WithAttempts(4),
+		retry.WithBackoff(time.Hour),
+		retry.WithExponential(),
+		retry.WithClock(clock),
+	)
+
+	fmt.Println(err, clock.Since(started))
+	// Output: no attempts left: unavailable 7h0m0s
+}
+
+func ExampleSleep() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	fmt.Println(retry.Sleep(context.Background(), time.Millisecond))
+	fmt.Println(retry.Sleep(ctx, time.Hour))
+	// Output:
+	// <nil>
+	// context deadline exceeded
+}
+
+func ExampleWithMaxRetriesPer() {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	started := clock.Now()
+
+	policy := retry.New(retry.Config{}.With(
+		retry.WithAttempts(5),
+		retry.WithBackoff(time.Second),
+		retry.WithMaxRetriesPer(time.Minute, 2),
+		retry.WithClock(clock),
+	))
+
+	err := policy.Do(context.TODO(), func() (bool, error) {
+		return true, errors.New("quota exceeded")
+	})
+
+	fmt.Println(policy)
+	fmt.Println(err, clock.Since(started))
+	// Output:
+	// 5 attempts, linear backoff 1s, at most 2 retries per 1m0s
+	// no attempts left: quota exceeded 1m2s
+}
+
+func ExampleRetry_MaxRetriesPer_softDeadline() {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	failing := func() (bool, error) { return true, errors.New("quota exceeded") }
+
+	// one retry per minute of all calls sharing the policy
+	limited := retry.Attempts(2).MaxRetriesPer(time.Minute, 1).Clock(clock)
+	hasty := limited.SoftDeadline(time.Second)
+
+	_ = limited.Do(context.TODO(), failing)
+
+	// the retry would start past the soft deadline, so its slot is left free
+	_ = hasty.Do(context.TODO(), failing)
+
+	started := clock.Now()
+	_ = limited.Do(context.TODO(), failing)
+	fmt.Println(clock.Since(started))
+	// Output: 1m0s
+}
+
+func ExampleRetry_Aligned() {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 10, 0, 7, 0, time.UTC)}
+
+	policy := retry.Attempts(3).Backoff(time.Second).Aligned(15 * time.Second).Clock(clock)
+
+	var attempts []string
+	_ = policy.Do(context.TODO(), func() (bool, error) {
+		attempts = append(attempts, clock.Now().Format("15:04:05"))
+		return true, errors.New("quota exceeded")
+	})
+
+	fmt.Println(policy)
+	fmt.Println(attempts)
+	// Output:
+	// 3 attempts, linear backoff 1s, aligned to 15s
+	// [10:00:07 10:00:15 10:00:30]
+}
+
+func ExampleWithHours() {
+	clock := &fakeClock{now: time.Date(2020, 1, 3, 16, 59, 0, 0, time.UTC)}
+
+	var attempts []string
+	_ = retry.Do(context.TODO(), func() (bool, error) {
+		attempts = append(attempts, clock.Now().Format("Mon 15:04"))
+		return true, errors.New("partner is down")
+	},
+		retry.WithAttempts(3),
+		retry.WithBackoff(30*time.Second),
+		retry.WithHours(backoff.BusinessHours(time.UTC)),
+		retry.WithClock(clock),
+	)
+
+	fmt.Println(attempts)
+	// Output: [Fri 16:59 Fri 16:59 Mon 09:00]
+}
+
+func ExampleSession_MarshalBinary() {
+	policy := retry.Attempts(3).ExponentialBackoff(time.Millisecond)
+
+	session := policy.NewSession()
+	_ = session.Do(context.TODO(), func() (bool, error) {
+		return true, errors.New("unavailable")
+	})
+
+	// saved before restart
+	state, _ := session.MarshalBinary()
+
+	var trace strings.Builder
+	restored := policy.Explain(&trace).NewSession()
+	if err := restored.UnmarshalBinary(state); err != nil {
+		fmt.Println(err)
+		return
+	}
+	_ = restored.Do(context.TODO(), func() (bool, error) {
+		return true, errors.New("unavailable")
+	})
+
+	fmt.Print(trace.String())
+	// Output:
+	// attempt 1 failed: unavailable; retryable, sleeping 4ms
+	// attempt 2 failed: unavailable; retryable, sleeping 8ms
+	// attempt 3 failed: unavailable; retryable, but no attempts left
+}
+
+func ExampleWithGate() {
+	var leader bool
+	gate := retry.GateFunc(func(ctx context.Context) bool { return leader })
+
+	var calls int
+	task := func() (bool, error) {
+		calls++
+		return true, errors.New("unavailable")
+	}
+
+	// follower
+	err := retry.Do(context.TODO(), task, retry.WithAttempts(3), retry.WithGate(gate))
+	fmt.Println(err, calls)
+
+	leader = true
+	err = retry.Do(context.TODO(), task, retry.WithAttempts(3), retry.WithGate(gate))
+	fmt.Println(err, calls)
+	// Output:
+	// retry: gate closed 0
+	// no attempts left: unavailable 3
+}
+
+func ExampleLocked() {
+	var mu sync.Mutex
+	policy := retry.Locked(&mu, retry.Attempts(3).Backoff(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	attempted := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- policy.Do(ctx, func() (bool, error) {
+			close(attempted)
+			return true, errors.New("unavailable")
+		})
+	}()
+
+	<-attempted
+	// the lock is available while the retry loop sleeps
+	mu.Lock()
+	fmt.Println("locked")
+	mu.Unlock()
+
+	cancel()
+	fmt.Println(<-done)
+	// Output:
+	// locked
+	// context canceled
+}
+
+func ExampleWithAdaptiveAttempts() {
+	adaptive := retry.NewAdaptiveAttempts(10, 0.9, 0.5, 1)
+	policy := retry.New(retry.Config{}.With(
+		retry.WithAttempts(5),
+		retry.WithAdaptiveAttempts(adaptive),
+	))
+
+	var calls int
+	outage := func() (bool, error) {
+		calls++
+		return true, errors.New("unavailable")
+	}
+
+	// the first calls are retried until the outage is confirmed
+	for i := 0; i < 4; i++ {
+		_ = policy.Do(context.TODO(), outage)
+	}
+
+	fmt.Println(calls, adaptive.Shrunk())
+	// Output: 12 true
+}
+
+func ExampleWithAttemptsByClass() {
+	classOf := func(err error) retry.Class {
+		var apiErr apiError
+		if errors.As(err, &apiErr) {
+			return retry.Class(apiErr.ErrorCode())
+		}
+		return ""
+	}
+
+	var calls int
+	err := retry.Do(context.TODO(), func() (bool, error) {
+		calls++
+		return true, apiError{code: "Throttling"}
+	},
+		retry.WithAttempts(5),
+		retry.WithAttemptsByClass(classOf, map[retry.Class]int{"Throttling": 2, "AccessDenied": 0}),
+	)
+
+	fmt.Println(err, calls)
+	// Output: no attempts left: api error Throttling 3
+}
+
+func ExampleSwitch() {
+	classOf := func(err error) retry.Class {
+		if retry.OnThrottling()(err) {
+			return "throttling"
+		}
+		return ""
+	}
+
+	var trace strings.Builder
+	policy := retry.Switch(classOf, map[retry.Class]retry.Retry{
+		"throttling": retry.Attempts(3).ExponentialBackoff(4 * time.Millisecond),
+	}, retry.Attempts(3).Backoff(time.Millisecond).Explain(&trace))
+
+	errs := []error{
+		apiError{code: "Throttling"},
+		errors.New("connection reset"),
+		apiError{code: "Throttling"},
+		errors.New("connection reset"),
+		apiError{code: "Throttling"},
+	}
+
+	var calls int
+	err := policy.Do(context.TODO(), func() (bool, error) {
+		calls++
+		return true, errs[calls-1]
+	})
+
+	fmt.Println(err)
+	fmt.Print(trace.String())
+	// Output:
+	// no attempts left: api error Throttling
+	// attempt 1 failed: api error Throttling; retryable, sleeping 4ms
+	// attempt 2 failed: connection reset; retryable, sleeping 1ms
+	// attempt 3 failed: api error Throttling; retryable, sleeping 8ms
+	// attempt 4 failed: connection reset; retryable, sleeping 1ms
+	// attempt 5 failed: api error Throttling; retryable, but no attempts left
+}
+
+func ExampleRetry_Then() {
+	policy := retry.Attempts(4).Backoff(time.Millisecond).
+		Then(retry.Attempts(5).ExponentialBackoff(2 * time.Millisecond))
+
+	stats, err := policy.DoWithStats(context.TODO(), func() (bool, error) {
+		return true, errors.New("unavailable")
+	})
+
+	fmt.Println(policy)
+	fmt.Println(err, stats.Attempts, stats.Elapsed >= 3*time.Millisecond+62*time.Millisecond)
+	// Output:
+	// 4 attempts, linear backoff 1ms; then 5 attempts, exponential backoff 2ms..16ms
+	// no attempts left: unavailable 9 true
+}
+
+func ExampleWithPriority() {
+	base := retry.Config{}.With(
+		retry.WithAttempts(4),
+		retry.WithBackoff(time.Millisecond),
+	)
+
+	var trace strings.Builder
+	background := retry.New(base.With(
+		retry.WithPriority(retry.PriorityBackground),
+		retry.WithPriorityScaler(func(p retry.Priority) (attempts, backoff float64) {
+			return 0.5, 10
+		}),
+		retry.WithExplain(&trace),
+	))
+
+	_ = background.Do(context.TODO(), func() (bool, error) {
+		return true, errors.New("unavailable")
+	})
+
+	fmt.Print(trace.String())
+	// Output:
+	// attempt 1 failed: unavailable; retryable, sleeping 10ms
+	// attempt 2 failed: unavailable; retryable, but no attempts left
+}
+
+func ExampleWithContextOverrides() {
+	ctx := retry.WithContextOverrides(context.Background(), retry.Overrides{Attempts: 1})
+
+	var calls int
+	err := retry.Attempts(5).Do(ctx, func() (bool, error) {
+		calls++
+		return true, errors.New("unavailable")
+	})
+
+	fmt.Println(err, calls)
+	// Output: no attempts left: unavailable 1
+}
+
+// tenantKey is the context key of the tenant of a request
+type tenantKey struct{}
+
+func ExampleDoFor() {
+	provider := retry.PolicyProviderFunc(func(ctx context.Context) retry.Retry {
+		if ctx.Value(tenantKey{}) == "free" {
+			return retry.None()
+		}
+		return retry.Attempts(3)
+	})
+
+	for _, tenant := range []string{"free", "enterprise"} {
+		var calls int
+		ctx := context.WithValue(context.Background(), tenantKey{}, tenant)
+		_ = retry.DoFor(ctx, provider, func() (bool, error) {
+			calls++
+			return true, errors.New("unavailable")
+		})
+		fmt.Println(tenant, calls)
+	}
+	// Output:
+	// free 1
+	// enterprise 3
+}
+
+func ExampleDynamicRetry() {
+	dynamic := retry.NewDynamicRetry(retry.Attempts(10))
+
+	var calls int
+	err := dynamic.Do(context.TODO(), func() (bool, error) {
+		calls++
+		if calls == 2 {
+			// e.g. a config service reduced attempts during an incident
+			_ = dynamic.StoreConfig(retry.Config{Attempts: 3})
+		}
+		return true, errors.New("unavailable")
+	})
+
+	fmt.Println(err, calls)
+	// Output: no attempts left: unavailable 3
+}
+
+func ExampleDynamicRetry_DoContext() {
+	dynamic := retry.NewDynamicRetry(retry.Attempts(3).AttemptTimeout(time.Hour))
+
+	var timeouts []time.Duration
+	_ = dynamic.DoContext(context.TODO(), func(ctx context.Context) (bool, error) {
+		deadline, _ := ctx.Deadline()
+		timeouts = append(timeouts, time.Until(deadline).Round(time.Second))
+		// the attempts in flight pick up the new attempt timeout
+		dynamic.Store(retry.Attempts(3).AttemptTimeout(time.Minute))
+		return true, errors.New("unavailable")
+	})
+
+	fmt.Println(timeouts)
+	// Output: [1h0m0s 1m0s 1m0s]
+}
+
+func ExampleConfig_UnmarshalJSON() {
+	var cfg retry.Config
+	err := json.Unmarshal([]byte(`{"attempts":3,"backoff":"decorrelated","initial":"100ms","cap":"2s"}`), &cfg)
+
+	fmt.Println(retry.New(cfg), err)
+	// Output: 3 attempts, decorrelated backoff 100ms..2s <nil>
+}
+
+func ExampleConfig_UnmarshalJSON_roundTrip() {
+	policy := retry.Attempts(3).Backoff(10 * time.Millisecond).
+		SoftDeadline(time.Minute).
+		Then(retry.Forever().ExponentialBackoff(100 * time.Millisecond).MaxBackoff(5 * time.Second))
+
+	data, _ := json.Marshal(policy)
+	fmt.Println(string(data))
+
+	var cfg retry.Config
+	err := json.Unmarshal(data, &cfg)
+	fmt.Println(retry.New(cfg), err)
+	// Output:
+	// {"attempts":3,"backoff":"linear","initial":"10ms","max":"10ms","cap":"0s","soft_deadline":"1m0s","then":[{"attempts":9223372036854775807,"backoff":"exponential","initial":"100ms","cap":"5s"}]}
+	// 3 attempts, linear backoff 10ms, soft deadline 1m0s; then unlimited attempts, exponential backoff 100ms..5s <nil>
+}
+
+func ExampleWithEnabled() {
+	flags := map[string]bool{"retries": false}
+
+	var calls int
+	err := retry.Do(context.TODO(), func() (bool, error) {
+		calls++
+		return true, errors.New("unavailable")
+	},
+		retry.WithAttempts(3),
+		retry.WithEnabled(func(ctx context.Context) bool { return flags["retries"] }),
+	)
+
+	fmt.Println(err, calls)
+	// Output: unavailable 1
+}
+
+func ExampleAttemptsFromError() {
+	err := retry.Attempts(3).Do(context.TODO(), func() (bool, error) {
+		return true, errors.New("unavailable")
+	})
+
+	attempts, ok := retry.AttemptsFromError(err)
+	elapsed, _ := retry.ElapsedFromError(err)
+
+	fmt.Println(err, attempts, ok, elapsed >= 0)
+	// Output: no attempts left: unavailable 3 true true
+}
+
+// unavailableError hides the message of the cause
+type unavailableError struct {
+	attempts int
+	cause    error
+}
+
+func (e unavailableError) Error() string {
+	return fmt.Sprintf("SERVICE_UNAVAILABLE after %d attempts", e.attempts)
+}
+
+func (e unavailableError) Unwrap() error { return e.cause }
+
+func ExampleWithExhaustedError() {
+	errSecret := errors.New("connect to 10.0.0.1:5432 as admin: refused")
+
+	err := retry.Do(context.TODO(), func() (bool, error) {
+		return true, errSecret
+	},
+		retry.WithAttempts(2),
+		retry.WithExhaustedError(func(attempts int, lastErr error) error {
+			return unavailableError{attempts: attempts, cause: lastErr}
+		}),
+	)
+
+	fmt.Println(err, errors.Is(err, errSecret))
+	// Output: SERVICE_UNAVAILABLE after 2 attempts true
+}
+
+func ExampleWithProgressiveTimeout() {
+	policy := retry.New(retry.Config{}.With(
+		retry.WithAttempts(3),
+		retry.WithAttemptTimeout(10*time.Millisecond),
+		retry.WithProgressiveTimeout(),
+	))
+
+	var timeouts []time.Duration
+	_ = policy.DoContext(context.Background(), retry.Ctx(func(ctx context.Context) error {
+		deadline, _ := ctx.Deadline()
+		timeouts = append(timeouts, time.Until(deadline).Round(10*time.Millisecond))
+		return errors.New("slow")
+	}, nil))
+
+	fmt.Println(policy)
+	fmt.Println(timeouts)
+	// Output:
+	// 3 attempts, no backoff, attempt timeout 10ms doubling
+	// [10ms 20ms 40ms]
+}
+
+func ExampleDoHedged() {
+	err := retry.DoHedged(context.Background(), retry.Attempts(2), retry.Hedge{Replicas: 3},
+		func(ctx context.Context, replica int) error {
+			switch replica {
+			case 0:
+				fmt.Println("replica 0 failed")
+				return errors.New("unavailable")
+			case 1:
+				// slow replica, cancelled when replica 2 responds
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			fmt.Println("replica 2 served")
+			return nil
+		})
+
+	fmt.Println(err)
+	// Output:
+	// replica 0 failed
+	// replica 2 served
+	// <nil>
+}
+
+func ExampleWithSoftDeadline() {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	policy := retry.New(retry.Config{}.With(
+		retry.WithAttempts(10),
+		retry.WithBackoff(100*time.Millisecond),
+		retry.WithSoftDeadline(250*time.Millisecond),
+		retry.WithClock(clock),
+	))
+
+	var calls int
+	err := policy.Do(context.Background(), func() (bool, error) {
+		calls++
+		return true, errors.New("unavailable")
+	})
+
+	fmt.Println(policy)
+	fmt.Println(calls, err)
+	// Output:
+	// 10 attempts, linear backoff 100ms, soft deadline 250ms
+	// 3 no attempts left: unavailable
+}
+
+func ExampleWithInterruptible() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	unblock := make(chan struct{})
+	orphaned := make(chan error, 1)
+	err := retry.Do(ctx, func() (bool, error) {
+		// ignores cancellation
+		<-unblock
+		return true, errors.New("late reply")
+	},
+		retry.WithAttempts(3),
+		retry.WithInterruptible(func(err error) { orphaned <- err }),
+	)
+	fmt.Println(err)
+
+	close(unblock)
+	fmt.Println("orphaned:", <-orphaned)
+	// Output:
+	// context deadline exceeded
+	// orphaned: late reply
+}
+
+func ExampleWithInterruptible_panic() {
+	defer func() {
+		// the panic of Func is raised again by the goroutine of Do
+		fmt.Println("recovered:", recover())
+	}()
+
+	_ = retry.Do(context.TODO(), func() (bool, error) {
+		panic("nil map")
+	},
+		retry.WithAttempts(3),
+		retry.WithInterruptible(nil),
+	)
+	// Output: recovered: nil map
+}
+
+func ExampleWithStopSignals() {
+	// a worker stops retrying on Ctrl+C, letting the current attempt finish
+	err := retry.Do(context.Background(), func() (bool, error) {
+		return true, errors.New("unavailable")
+	},
+		retry.WithAttempts(retry.Unlimited),
+		retry.WithBackoff(time.Second),
+		retry.WithStopSignals(os.Interrupt),
+	)
+	if errors.Is(err, retry.ErrStopped) {
+		fmt.Println("stopped")
+	}
+}
+
+func ExampleScheduler_Shutdown() {
+	done := make(chan string, 1)
+	scheduler := retry.NewScheduler(retry.Attempts(5).JitterBackoff(time.Hour, 0),
+		retry.WithFastFail(),
+		retry.WithOnJobDone(func(id string, err error) {
+			if err == nil {
+				done <- id
+			}
+		}),
+	)
+
+	_ = scheduler.Submit("welcome-email", func(ctx context.Context) error { return nil })
+	_ = scheduler.Submit("webhook", func(ctx context.Context) error { return errors.New("unavailable") })
+	fmt.Println("done:", <-done)
+
+	abandoned, err := scheduler.Shutdown(context.Background())
+	fmt.Println("abandoned:", abandoned, err)
+	fmt.Println(scheduler.Submit("late", func(ctx context.Context) error { return nil }))
+	// Output:
+	// done: welcome-email
+	// abandoned: [webhook] <nil>
+	// retry: scheduler closed
+}
+
+func ExampleScheduler_Shutdown_timeout() {
+	scheduler := retry.NewScheduler(retry.Attempts(3),
+		retry.WithOnJobDone(func(id string, err error) {
+			fmt.Println("done:", id, err)
+		}),
+	)
+
+	started, stopped := make(chan struct{}), make(chan struct{})
+	_ = scheduler.Submit("report", func(ctx context.Context) error {
+		defer close(stopped)
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	_ = scheduler.Submit("backup", func(ctx context.Context) error { return nil })
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	abandoned, err := scheduler.Shutdown(ctx)
+	fmt.Println("abandoned:", abandoned, err)
+
+	// the running job is interrupted, and neither retried nor reported
+	<-stopped
+	time.Sleep(10 * time.Millisecond)
+	// Output: abandoned: [report backup] context deadline exceeded
+}
+
+type memoryStore struct {
+	records map[string]retry.Record
+}
+
+func (s *memoryStore) List(ctx context.Context, now time.Time, limit int) ([]retry.Record, error) {
+	var due []retry.Record
+	for _, rec := range s.records {
+		if !rec.NextAttempt.After(now) && len(due) < limit {
+			due = append(due, rec)
+		}
+	}
+	return due, nil
+}
+
+func (s *memoryStore) Claim(ctx context.Context, id string, lease time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, rec retry.Record, done bool) error {
+	if done {
+		fmt.Printf("%s done after %d attempts\n", rec.ID, rec.Attempts)
+		delete(s.records, rec.ID)
+		return nil
+	}
+	fmt.Printf("%s failed: %s, next attempt at %s\n", rec.ID, rec.LastError, rec.NextAttempt.Format(time.Kitchen))
+	s.records[rec.ID] = rec
+	return nil
+}
+
+func ExampleOutbox() {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)}
+	store := &memoryStore{records: map[string]retry.Record{"order-1": {ID: "order-1"}}}
+
+	policy := retry.New(retry.Config{}.With(
+		retry.WithAttempts(3),
+		retry.WithBackoff(time.Minute),
+		retry.WithExponential(),
+		retry.WithJitter(0),
+		retry.WithClock(clock),
+	))
+	outbox := retry.NewOutbox(store, policy, func(ctx context.Context, rec retry.Record) error {
+		if rec.Attempts < 2 {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	})
+
+	for i := 0; i < 4; i++ {
+		_, _ = outbox.RunOnce(context.Background())
+		clock.now = clock.now.Add(time.Minute)
+	}
+	// Output:
+	// order-1 failed: broker unavailable, next attempt at 9:01AM
+	// order-1 failed: broker unavailable, next attempt at 9:03AM
+	// order-1 done after 3 attempts
+}
+
+func ExampleScheduler_Schedule() {
+	done := make(chan string, 2)
+	scheduler := retry.NewScheduler(retry.Attempts(3),
+		retry.WithWorkers(2),
+		retry.WithOnJobDone(func(id string, err error) {
+			done <- fmt.Sprintf("%s: %v", id, err)
+		}),
+	)
+
+	reminder := time.Now().Add(50 * time.Millisecond)
+	_ = scheduler.Schedule(retry.Job{
+		ID:  "reminder",
+		Run: func(ctx context.Context) error { return nil },
+		At:  reminder,
+	})
+	report := retry.Attempts(5).JitterBackoff(time.Hour, 0)
+	_ = scheduler.Schedule(retry.Job{
+		ID:     "report",
+		Run:    func(ctx context.Context) error { return errors.New("warehouse unavailable") },
+		Policy: &report,
+		MaxAge: time.Minute,
+	})
+
+	fmt.Println(<-done)
+	next, _ := scheduler.NextWake()
+	fmt.Println(scheduler.Pending(), next.Equal(reminder))
+	fmt.Println(<-done)
+
+	_, _ = scheduler.Shutdown(context.Background())
+	// Output:
+	// report: no attempts left: warehouse unavailable
+	// 1 true
+	// reminder: <nil>
+}
+
+func ExampleJob_attemptTimeout() {
+	done := make(chan error, 1)
+	scheduler := retry.NewScheduler(retry.Attempts(2).AttemptTimeout(10*time.Millisecond),
+		retry.WithOnJobDone(func(id string, err error) { done <- err }),
+	)
+
+	var attempts int
+	_ = scheduler.Submit("export", func(ctx context.Context) error {
+		attempts++
+		// hangs until the attempt times out
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	fmt.Println(<-done, attempts)
+	_, _ = scheduler.Shutdown(context.Background())
+	// Output: no attempts left: context deadline exceeded 2
+}
+
+func ExampleWithDeterministic() {
+	elapsed := func(seed int64) time.Duration {
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		started := clock.Now()
+		_ = retry.Do(context.Background(), func() (bool, error) {
+			return true, errors.New("unavailable")
+		},
+			retry.WithAttempts(5),
+			retry.WithBackoff(time.Second),
+			retry.WithJitter(0.5),
+			retry.WithDeterministic(seed),
+			retry.WithClock(clock),
+		)
+		return clock.Since(started)
+	}
+
+	fmt.Println(elapsed(42) == elapsed(42), elapsed(42) == elapsed(7))
+	// Output: true false
+}
+
+func ExampleRetry_BackoffAt() {
+	policy := retry.Attempts(5).ExponentialJitterBackoff(100*time.Millisecond, 0.2).MaxBackoff(time.Second)
+
+	fmt.Println(policy.MaxAttempts(), policy.HasBackoff())
+	for attempt := 0; attempt < policy.MaxAttempts()-1; attempt++ {
+		fmt.Println(policy.BackoffAt(attempt))
+	}
+	// Output:
+	// 5 true
+	// 100ms
+	// 200ms
+	// 400ms
+	// 800ms
+}
+
+func ExampleFromConfig() {
+	var cfg retry.Config
+	_ = json.Unmarshal([]byte(`{"attempts":3,"backoff":"exponential","initial":"100ms"}`), &cfg)
+
+	policy := retry.FromConfig(cfg,
+		retry.WithJitter(0.1),
+		retry.WithRetryIf(func(err error) bool { return !errors.Is(err, os.ErrNotExist) }),
+	)
+
+	fmt.Println(policy)
+	fmt.Println(retry.NewPolicy(retry.WithAttempts(2), retry.WithBackoff(time.Second)))
+	// Output:
+	// 3 attempts, exponential backoff 100ms..200ms, jitter 10%
+	// 2 attempts, linear backoff 1s
+}
+
+// constantBackOff is a BackOff of a third-party library
+type constantBackOff struct {
+	delay time.Duration
+	left  int
+}
+
+func (b *constantBackOff) NextBackOff() time.Duration {
+	if b.left == 0 {
+		return retry.Stop
+	}
+	b.left--
+	return b.delay
+}
+
+func (b *constantBackOff) Reset() {}
+
+func ExampleDoBackOff() {
+	var calls int
+	err := retry.DoBackOff(context.Background(), retry.Forever(), &constantBackOff{delay: time.Millisecond, left: 2},
+		func() (bool, error) {
+			calls++
+			return true, errors.New("unavailable")
+		})
+
+	fmt.Println(calls, err)
+	// Output: 3 no attempts left: unavailable
+}
+
+func ExampleDoBackOff_onExhausted() {
+	policy := retry.Forever().OnExhausted(func(ctx context.Context, attempts int, lastErr error) {
+		fmt.Printf("alert: gave up after %d attempts: %v\n", attempts, lastErr)
+	})
+
+	err := retry.DoBackOff(context.Background(), policy, &constantBackOff{delay: time.Millisecond, left: 1},
+		func() (bool, error) {
+			return true, errors.New("unavailable")
+		})
+
+	fmt.Println(err)
+	// Output:
+	// alert: gave up after 2 attempts: unavailable
+	// no attempts left: unavailable
+}
+
+func ExampleRetry_BackOff() {
+	b := retry.Attempts(4).ExponentialJitterBackoff(time.Second, 0).BackOff()
+
+	for delay := b.NextBackOff(); delay != retry.Stop; delay = b.NextBackOff() {
+		fmt.Println(delay)
+	}
+	// Output:
+	// 1s
+	// 2s
+	// 4s
+}
+
+func ExampleWithRetryOnDeadline() {
+	policy := retry.New(retry.Config{}.With(
+		retry.WithAttempts(3),
+		retry.WithAttemptTimeout(time.Millisecond),
+		retry.WithRetryOnDeadline(true),
+	))
+
+	var calls int
+	err := policy.DoContext(context.Background(), retry.Ctx(func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			<-ctx.Done() // the first attempt hangs
+			return ctx.Err()
+		}
+		return nil
+	}, func(err error) bool { return false }))
+	fmt.Println(calls, err)
+
+	// the deadline of the caller is not retried
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	calls = 0
+	err = policy.DoContext(ctx, retry.Ctx(func(ctx context.Context) error {
+		calls++
+		<-ctx.Done()
+		return ctx.Err()
+	}, nil))
+	fmt.Println(calls, err)
+	// Output:
+	// 2 <nil>
+	// 1 context deadline exceeded
+}
+
+func ExampleWithMinBackoff() {
+	// the upstream rejects calls closer than 80ms to each other,
+	// jitter of 50% would make some delays as short as 50ms
+	policy := retry.NewPolicy(
+		retry.WithAttempts(100),
+		retry.WithBackoff(100*time.Millisecond),
+		retry.WithJitter(0.5),
+		retry.WithMinBackoff(80*time.Millisecond),
+	)
+	fmt.Println(policy)
+
+	shortest := time.Hour
+	b := policy.BackOff()
+	for delay := b.NextBackOff(); delay != retry.Stop; delay = b.NextBackOff() {
+		if delay < shortest {
+			shortest = delay
+		}
+	}
+	fmt.Println(shortest)
+	// Output:
+	// 100 attempts, linear backoff 100ms, jitter 50%, min backoff 80ms
+	// 80ms
+}
+
+func ExampleWithExactFirstRetry() {
+	policy := retry.NewPolicy(
+		retry.WithAttempts(4),
+		retry.WithBackoff(100*time.Millisecond),
+		retry.WithJitter(0.5),
+		retry.WithExactFirstRetry(),
+	)
+	fmt.Println(policy)
+
+	// the first retry recovers from a blip predictably,
+	// later ones are spread by jitter
+	b := policy.BackOff()
+	fmt.Println(b.NextBackOff())
+	// Output:
+	// 4 attempts, linear backoff 100ms, jitter 50%, exact first retry
+	// 100ms
+}
+
+func ExampleWithImmediateRetries() {
+	policy := retry.NewPolicy(
+		retry.WithAttempts(6),
+		retry.WithBackoff(100*time.Millisecond),
+		retry.WithExponential(),
+		retry.WithImmediateRetries(2),
+	)
+	fmt.Println(policy)
+
+	for attempt := 0; attempt < 5; attempt++ {
+		fmt.Println(policy.BackoffAt(attempt))
+	}
+	// Output:
+	// 6 attempts, 2 immediate retries, exponential backoff 100ms..400ms
+	// 0s
+	// 0s
+	// 100ms
+	// 200ms
+	// 400ms
+}
+
+func ExampleWithLogarithmic() {
+	policy := retry.NewPolicy(
+		retry.WithAttempts(8),
+		retry.WithBackoff(100*time.Millisecond),
+		retry.WithLogarithmic(),
+	)
+	fmt.Println(policy)
+
+	for _, attempt := range []int{0, 2, 6} {
+		fmt.Println(policy.BackoffAt(attempt))
+	}
+	// Output:
+	// 8 attempts, logarithmic backoff 100ms..300ms
+	// 100ms
+	// 200ms
+	// 300ms
+}
+
+func ExampleWithJitterDuration() {
+	// 20% of 5ms would spread retries of many clients within 2ms only
+	policy := retry.NewPolicy(
+		retry.WithAttempts(100),
+		retry.WithBackoff(5*time.Millisecond),
+		retry.WithJitterDuration(50*time.Millisecond),
+	)
+	fmt.Println(policy)
+
+	longest := time.Duration(0)
+	b := policy.BackOff()
+	for delay := b.NextBackOff(); delay != retry.Stop; delay = b.NextBackOff() {
+		if delay > longest {
+			longest = delay
+		}
+	}
+	fmt.Println(longest > 40*time.Millisecond, longest <= 55*time.Millisecond)
+	// Output:
+	// 100 attempts, linear backoff 5ms, jitter ±50ms
+	// true true
+}
+
+func ExampleWithJitterSeed() {
+	delays := func(policy retry.Retry) []time.Duration {
+		var delays []time.Duration
+		b := policy.BackOff()
+		for delay := b.NextBackOff(); delay != retry.Stop; delay = b.NextBackOff() {
+			delays = append(delays, delay)
+		}
+		return delays
+	}
+	opts := []retry.Option{
+		retry.WithAttempts(4),
+		retry.WithBackoff(100 * time.Millisecond),
+		retry.WithJitter(0.5),
+	}
+
+	// e.g. two runs of a benchmark
+	first := retry.NewPolicy(append(opts, retry.WithJitterSeed(42))...)
+	second := retry.NewPolicy(append(opts, retry.WithJitterSeed(42))...)
+
+	fmt.Println(fmt.Sprint(delays(first)) == fmt.Sprint(delays(second)))
+	fmt.Println(fmt.Sprint(delays(first)) == fmt.Sprint(delays(second)))
+	// the sequence continues instead of repeating, unlike WithDeterministic
+	fmt.Println(fmt.Sprint(delays(first)) == fmt.Sprint(delays(first)))
+	// Output:
+	// true
+	// true
+	// false
+}
+
+func ExampleStats_slept() {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	policy := retry.NewPolicy(
+		retry.WithAttempts(3),
+		retry.WithBackoff(10*time.Second),
+		retry.WithClock(clock),
+	)
+
+	stats, err := policy.DoWithStats(context.TODO(), func() (bool, error) {
+		clock.now = clock.now.Add(time.Second) // a slow dependency
+		return true, errors.New("unavailable")
+	})
+	fmt.Println(err)
+	fmt.Println("elapsed", stats.Elapsed, "slept", stats.Slept, "in calls", stats.Elapsed-stats.Slept)
+
+	slept, _ := retry.SleptFromError(err)
+	fmt.Println("slept", slept)
+	// Output:
+	// no attempts left: unavailable
+	// elapsed 23s slept 20s in calls 3s
+	// slept 20s
+}
+
+func ExampleStats_history() {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	policy := retry.NewPolicy(
+		retry.WithAttempts(5),
+		retry.WithBackoff(30*time.Second),
+		retry.WithExponential(),
+		retry.WithClock(clock),
+	)
+
+	var calls int
+	stats, err := policy.DoWithStats(context.TODO(), func() (bool, error) {
+		calls++
+		clock.now = clock.now.Add(time.Second)
+		if calls < 3 {
+			return true, errors.New("unavailable")
+		}
+		return false, nil
+	})
+
+	fmt.Println(err)
+	for _, attempt := range stats.History {
+		fmt.Println(attempt.Start.Format("15:04:05"), attempt.End.Format("15:04:05"), attempt.Err)
+	}
+	// Output:
+	// <nil>
+	// 12:00:00 12:00:01 unavailable
+	// 12:00:31 12:00:32 unavailable
+	// 12:01:32 12:01:33 <nil>
+}
+
+func ExampleRetry_BackoffRangeAt() {
+	policy := retry.Attempts(4).ExponentialJitterBackoff(100*time.Millisecond, 0.2).
+		MaxBackoff(200 * time.Millisecond).
+		ImmediateRetries(1)
+
+	for attempt := 0; attempt < policy.MaxAttempts()-1; attempt++ {
+		fmt.Println(policy.BackoffRangeAt(attempt))
+	}
+	// Output:
+	// 0s 0s
+	// 80ms 120ms
+	// 160ms 200ms
+}
@@ -0,0 +1,44 @@
+package retry
+
+import (
+	"fmt"
+	"time"
+)
+
+// explainf writes a line of the trace of decisions, if enabled
+func (r *Retry) explainf(format string, args ...interface{}) {
+	if r.explain != nil {
+		fmt.Fprintf(r.explain, format+"\n", args...)
+	}
+}
+
+// explainResult explains why Do returns after zero-based attempt
+func (r *Retry) explainResult(attempt int, err error) {
+	switch {
+	case r.explain == nil:
+	case err == nil:
+		r.explainf("attempt %d succeeded", attempt+1)
+	case aborted(err):
+		r.explainf("attempt %d failed: %v; aborted", attempt+1, err)
+	default:
+		r.explainf("attempt %d failed: %v; permanent, giving up", attempt+1, err)
+	}
+}
+
+// explainDelay explains the backoff after zero-based attempt failed with err
+func (r Retry) explainDelay(attempt int, err error, delay, base time.Duration) {
+	switch _, requested := requestedDelay(err); {
+	case r.explain == nil:
+	case requested:
+		r.explainf("attempt %d failed: %v; retryable, sleeping %s (requested by RetryAfter)", attempt+1, err, delay)
+	case delay <= 0:
+		r.explainf("attempt %d failed: %v; retryable, retrying immediately", attempt+1, err)
+	case r.decorrelated:
+		r.explainf("attempt %d failed: %v; retryable, sleeping %s (decorrelated)", attempt+1, err, delay)
+	case delay == base:
+		r.explainf("attempt %d failed: %v; retryable, sleeping %s", attempt+1, err, delay)
+	default:
+		r.explainf("attempt %d failed: %v; retryable, sleeping %s (base %s, jitter %+.0f%%)",
+			attempt+1, err, delay, base, (float64(delay)/float64(base)-1)*100)
+	}
+}
@@ -0,0 +1,91 @@
+package retry
+
+import "sync"
+
+// defaultAttemptsWindow is the window of AdaptiveAttempts created
+// with non-positive window
+const defaultAttemptsWindow = 100
+
+// AdaptiveAttempts shrinks the attempts of Do calls during confirmed
+// outages to fail fast: once the fraction of attempts failed with temporary
+// errors among the last window attempts reaches high, Do calls make at most
+// min attempts; the attempts of the policy are restored once the fraction
+// drops to low. It is safe for concurrent use and is meant to be shared
+// by all calls to one dependency, see WithAdaptiveAttempts.
+type AdaptiveAttempts struct {
+	mu        sync.Mutex
+	high, low float64
+	min       int
+	// outcomes is a ring buffer of the last attempts, true if failed
+	outcomes []bool
+	next     int
+	recorded int
+	failures int
+	shrunk   bool
+}
+
+// NewAdaptiveAttempts returns AdaptiveAttempts with the failure rate
+// thresholds high and low (low is expected to be less than high) over
+// the last window attempts, 100 if window is not positive. Min attempts
+// during an outage are at least 1.
+func NewAdaptiveAttempts(window int, high, low float64, min int) *AdaptiveAttempts {
+	if window <= 0 {
+		window = defaultAttemptsWindow
+	}
+	if low > high {
+		low = high
+	}
+	if min < 1 {
+		min = 1
+	}
+	return &AdaptiveAttempts{high: high, low: low, min: min, outcomes: make([]bool, window)}
+}
+
+// attempts returns the attempts of a Do call of the policy of attempts
+func (a *AdaptiveAttempts) attempts(attempts int) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.shrunk && attempts > a.min {
+		return a.min
+	}
+	return attempts
+}
+
+// record records the outcome of an attempt
+func (a *AdaptiveAttempts) record(failed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.recorded == len(a.outcomes) {
+		if a.outcomes[a.next] {
+			a.failures--
+		}
+	} else {
+		a.recorded++
+	}
+	a.outcomes[a.next] = failed
+	if failed {
+		a.failures++
+	}
+	a.next = (a.next + 1) % len(a.outcomes)
+
+	// the rate is trusted only when the window is full
+	if a.recorded < len(a.outcomes) {
+		return
+	}
+	rate := float64(a.failures) / float64(a.recorded)
+	switch {
+	case !a.shrunk && rate >= a.high:
+		a.shrunk = true
+	case a.shrunk && rate <= a.low:
+		a.shrunk = false
+	}
+}
+
+// Shrunk reports whether the attempts are shrunk due to an outage.
+func (a *AdaptiveAttempts) Shrunk() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.shrunk
+}
@@ -0,0 +1,34 @@
+package retry
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrGateClosed is returned by Do when Gate denies the attempt.
+var ErrGateClosed = errors.New("retry: gate closed")
+
+// Gate allows attempts only in the process holding a role, e.g. the elected
+// leader of a singleton task, so followers return immediately instead of
+// retrying the shared task. For example, with etcd election:
+//
+//	type leader struct{ session *concurrency.Session; election *concurrency.Election }
+//
+//	func (l leader) Allow(ctx context.Context) bool {
+//		resp, err := l.election.Leader(ctx)
+//		return err == nil && l.session.Lease() == clientv3.LeaseID(resp.Kvs[0].Lease)
+//	}
+//
+// Gate must be safe for concurrent use.
+type Gate interface {
+	// Allow reports whether the process may make an attempt.
+	Allow(ctx context.Context) bool
+}
+
+// GateFunc adapts a function to Gate.
+type GateFunc func(ctx context.Context) bool
+
+// Allow calls f.
+func (f GateFunc) Allow(ctx context.Context) bool {
+	return f(ctx)
+}
@@ -0,0 +1,75 @@
+package retry
+
+import "context"
+
+// Hedge configures DoHedged.
+type Hedge struct {
+	// Replicas is the number of replicas able to serve the call.
+	Replicas int
+	// Width is the number of replicas called concurrently by each retry,
+	// 2 if it is not positive.
+	Width int
+}
+
+// ReplicaFunc calls a replica with zero-based index in range [0, Replicas).
+type ReplicaFunc func(ctx context.Context, replica int) error
+
+// DoHedged calls a replica with the policy: the first attempt calls a single
+// replica, each retry calls Width different replicas concurrently and
+// takes the first success, cancelling the others, as latency-sensitive
+// storage clients do. Replicas are picked round-robin, so retries avoid
+// the replicas failed before. Errors are classified by WithRetryIf,
+// every error is retried by default.
+func DoHedged(ctx context.Context, policy Retry, hedge Hedge, call ReplicaFunc) error {
+	if hedge.Replicas < 1 {
+		hedge.Replicas = 1
+	}
+	if hedge.Width < 1 {
+		hedge.Width = 2
+	}
+	if hedge.Width > hedge.Replicas {
+		hedge.Width = hedge.Replicas
+	}
+
+	var next int
+	return policy.DoContext(ctx, Ctx(func(ctx context.Context) error {
+		if next == 0 {
+			next++
+			return call(ctx, 0)
+		}
+
+		first := next
+		next += hedge.Width
+		return hedged(ctx, hedge, first, call, policy.retryIf)
+	}, policy.retryIf))
+}
+
+// hedged calls Width replicas starting from first concurrently, returns nil
+// on the first success, otherwise a permanent error if any, or the last error
+func hedged(ctx context.Context, hedge Hedge, first int, call ReplicaFunc, classifier Classifier) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan error, hedge.Width)
+	for i := 0; i < hedge.Width; i++ {
+		replica := (first + i) % hedge.Replicas
+		go func() {
+			results <- call(ctx, replica)
+		}()
+	}
+
+	var err error
+	for i := 0; i < hedge.Width; i++ {
+		result := <-results
+		switch {
+		case result == nil:
+			return nil
+		case !classify(result, classifier):
+			// the permanent error wins over temporary ones
+			err = Abort(result)
+		case err == nil || !aborted(err):
+			err = result
+		}
+	}
+	return err
+}
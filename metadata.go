@@ -0,0 +1,67 @@
+package retry
+
+import (
+	"errors"
+	"time"
+)
+
+// metadataError is the error returned by Do carrying the attempts,
+// the elapsed time and the time slept in backoff of the call
+type metadataError struct {
+	err      error
+	attempts int
+	elapsed  time.Duration
+	slept    time.Duration
+}
+
+func (e *metadataError) Error() string {
+	return e.err.Error()
+}
+
+func (e *metadataError) Unwrap() error {
+	return e.err
+}
+
+// AttemptsFromError returns the number of Func calls made by Do
+// returned err, ok is false if err is not returned by Do.
+// Errors of nested Do calls report the outermost call.
+func AttemptsFromError(err error) (attempts int, ok bool) {
+	var meta *metadataError
+	if !errors.As(err, &meta) {
+		return 0, false
+	}
+	return meta.attempts, true
+}
+
+// ElapsedFromError returns the time Do call returned err spent retrying:
+// since the first attempt failed, including backoff, zero if it was the only
+// one. Ok is false if err is not returned by Do. The time of the first
+// attempt is not included, so successful calls don't read the clock,
+// see Stats.Elapsed for the total time.
+func ElapsedFromError(err error) (elapsed time.Duration, ok bool) {
+	var meta *metadataError
+	if !errors.As(err, &meta) {
+		return 0, false
+	}
+	return meta.elapsed, true
+}
+
+// SleptFromError returns the time Do call returned err slept in backoff,
+// see Stats.Slept, ok is false if err is not returned by Do.
+func SleptFromError(err error) (slept time.Duration, ok bool) {
+	var meta *metadataError
+	if !errors.As(err, &meta) {
+		return 0, false
+	}
+	return meta.slept, true
+}
+
+// Cause returns err returned by Do without the metadata of the call,
+// e.g. to compare it with sentinel errors by ==. Other errors are
+// returned as is.
+func Cause(err error) error {
+	if meta, ok := err.(*metadataError); ok {
+		return meta.err
+	}
+	return err
+}
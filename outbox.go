@@ -0,0 +1,134 @@
+package retry
+
+import (
+	"context"
+	"time"
+)
+
+// Record is a pending delivery of Outbox, e.g. a row of the outbox table
+// written in the same transaction as the business data.
+type Record struct {
+	ID      string
+	Payload []byte
+	// Attempts is the number of attempts made so far.
+	Attempts int
+	// NextAttempt is the time the record is due.
+	NextAttempt time.Time
+	// LastError is the message of the error of the last attempt.
+	LastError string
+}
+
+// Store keeps records of Outbox, e.g. in the database of the service.
+// Store must be safe for concurrent use.
+type Store interface {
+	// List returns up to limit records due at now.
+	List(ctx context.Context, now time.Time, limit int) ([]Record, error)
+	// Claim leases the record to the caller for lease, so concurrent
+	// executors don't deliver it twice, false means it is claimed already.
+	Claim(ctx context.Context, id string, lease time.Duration) (bool, error)
+	// Update saves the record after an attempt, done reports that it
+	// must not be attempted anymore: delivered, failed permanently
+	// or out of attempts.
+	Update(ctx context.Context, rec Record, done bool) error
+}
+
+// OutboxOption configures Outbox.
+type OutboxOption func(*Outbox)
+
+// WithBatch limits the number of records delivered by Outbox.RunOnce, 100 by default.
+func WithBatch(n int) OutboxOption {
+	return func(o *Outbox) {
+		o.batch = n
+	}
+}
+
+// WithLease sets the duration records are claimed for, 1 minute by default.
+// The lease must outlast the delivery of a record.
+func WithLease(lease time.Duration) OutboxOption {
+	return func(o *Outbox) {
+		o.lease = lease
+	}
+}
+
+// Outbox delivers records of Store with a policy: unlike Do, it doesn't
+// sleep between attempts, but writes the time of the next attempt back
+// to Store, so the retries survive restarts of the process. It is the
+// building block of transactional outbox delivery.
+type Outbox struct {
+	store   Store
+	policy  Retry
+	deliver func(ctx context.Context, rec Record) error
+	batch   int
+	lease   time.Duration
+}
+
+// NewOutbox returns Outbox delivering records of store by deliver with
+// the policy. Errors of deliver are classified by WithRetryIf of the policy,
+// every error is retried by default.
+func NewOutbox(store Store, policy Retry, deliver func(ctx context.Context, rec Record) error, opts ...OutboxOption) *Outbox {
+	o := &Outbox{store: store, policy: policy, deliver: deliver, batch: 100, lease: time.Minute}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// RunOnce makes an attempt to deliver each due record and returns
+// the number of attempts made, records claimed by other executors are skipped.
+func (o *Outbox) RunOnce(ctx context.Context) (int, error) {
+	records, err := o.store.List(ctx, o.policy.now(), o.batch)
+	if err != nil {
+		return 0, err
+	}
+
+	var attempts int
+	for _, rec := range records {
+		claimed, err := o.store.Claim(ctx, rec.ID, o.lease)
+		if err != nil {
+			return attempts, err
+		}
+		if !claimed {
+			continue
+		}
+
+		attempts++
+		rec, done := o.attempt(ctx, rec)
+		if err := o.store.Update(ctx, rec, done); err != nil {
+			return attempts, err
+		}
+	}
+	return attempts, nil
+}
+
+// attempt delivers the record and returns it updated for Store
+func (o *Outbox) attempt(ctx context.Context, rec Record) (Record, bool) {
+	err := o.deliver(ctx, rec)
+	rec.Attempts++
+	if err == nil {
+		rec.LastError = ""
+		return rec, true
+	}
+
+	rec.LastError = unwrapAbort(err).Error()
+	if !classify(err, o.policy.retryIf) || rec.Attempts >= o.policy.attempts {
+		return rec, true
+	}
+
+	state := backoffState{failures: rec.Attempts - 1}
+	delay, _ := o.policy.delayAfter(&state, err)
+	rec.NextAttempt = o.policy.now().Add(delay)
+	return rec, false
+}
+
+// Run calls RunOnce every interval until ctx is done,
+// errors of RunOnce are returned.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		if _, err := o.RunOnce(ctx); err != nil {
+			return err
+		}
+		if err := o.policy.sleep(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// pacer is a leaky bucket spacing retries evenly
+type pacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	// next is the earliest time of the next retry
+	next time.Time
+}
+
+// newPacer returns pacer of retriesPerSecond, nil if it is not positive
+func newPacer(retriesPerSecond float64) *pacer {
+	if retriesPerSecond <= 0 {
+		return nil
+	}
+	return &pacer{interval: time.Duration(float64(time.Second) / retriesPerSecond)}
+}
+
+// until returns the delay until the slot of a retry after delay from now
+// without reserving it, so the retry can still be given up
+func (p *pacer) until(now time.Time, delay time.Duration) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.slot(now, delay).Sub(now)
+}
+
+// reserve reserves the slot of a retry after delay from now
+// and returns the delay until the slot
+func (p *pacer) reserve(now time.Time, delay time.Duration) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slot := p.slot(now, delay)
+	p.next = slot.Add(p.interval)
+	return slot.Sub(now)
+}
+
+// slot returns the earliest free slot after delay from now, p must be locked
+func (p *pacer) slot(now time.Time, delay time.Duration) time.Time {
+	slot := now.Add(delay)
+	if slot.Before(p.next) {
+		slot = p.next
+	}
+	return slot
+}
+
+// rate returns retries per second of pacer, zero if it is nil
+func (p *pacer) rate() float64 {
+	if p == nil {
+		return 0
+	}
+	return float64(time.Second) / float64(p.interval)
+}
+
+// retryWindow limits retries to n per sliding window
+type retryWindow struct {
+	mu     sync.Mutex
+	window time.Duration
+	// slots are the times of the last n reserved retries, a ring buffer,
+	// zero time is a free slot
+	slots []time.Time
+	next  int
+}
+
+// newRetryWindow returns retryWindow of n retries per window,
+// nil if either is not positive
+func newRetryWindow(window time.Duration, n int) *retryWindow {
+	if window <= 0 || n <= 0 {
+		return nil
+	}
+	return &retryWindow{window: window, slots: make([]time.Time, n)}
+}
+
+// until returns the delay until the slot of a retry after delay from now
+// without reserving it, so the retry can still be given up
+func (w *retryWindow) until(now time.Time, delay time.Duration) time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.slot(now, delay).Sub(now)
+}
+
+// reserve reserves the slot of a retry after delay from now
+// and returns the delay until the slot: not earlier than a window after
+// the n-th previous retry
+func (w *retryWindow) reserve(now time.Time, delay time.Duration) time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	slot := w.slot(now, delay)
+	w.slots[w.next] = slot
+	w.next = (w.next + 1) % len(w.slots)
+	return slot.Sub(now)
+}
+
+// slot returns the earliest free slot after delay from now, w must be locked
+func (w *retryWindow) slot(now time.Time, delay time.Duration) time.Time {
+	slot := now.Add(delay)
+	if oldest := w.slots[w.next]; !oldest.IsZero() && slot.Before(oldest.Add(w.window)) {
+		slot = oldest.Add(w.window)
+	}
+	return slot
+}
+
+// limit returns the window and the max number of retries in it,
+// zeros if w is nil
+func (w *retryWindow) limit() (time.Duration, int) {
+	if w == nil {
+		return 0, 0
+	}
+	return w.window, len(w.slots)
+}
@@ -0,0 +1,78 @@
+package retry
+
+import (
+	"time"
+
+	"github.com/osvim/retry/backoff"
+)
+
+// Priority is the priority of work retried by Retry, lower priorities get
+// fewer attempts and longer backoff by PriorityScaler.
+type Priority int
+
+// Priorities of work, the zero Priority is interactive.
+const (
+	// PriorityInteractive is the priority of requests waited by users,
+	// the policy is not scaled.
+	PriorityInteractive Priority = iota
+	// PriorityBatch is the priority of batch jobs.
+	PriorityBatch
+	// PriorityBackground is the priority of background work, e.g. backfills.
+	PriorityBackground
+)
+
+// PriorityScaler returns the multipliers of the attempts and the backoff
+// of a policy for work of priority p.
+type PriorityScaler func(p Priority) (attempts, backoff float64)
+
+// DefaultPriorityScaler halves attempts and doubles backoff of batch jobs,
+// keeps a quarter of attempts and quadruples backoff of background work.
+func DefaultPriorityScaler(p Priority) (attempts, backoff float64) {
+	switch {
+	case p <= PriorityInteractive:
+		return 1, 1
+	case p == PriorityBatch:
+		return 0.5, 2
+	default:
+		return 0.25, 4
+	}
+}
+
+// scaledAttempts returns attempts scaled by the priority, at least one
+func (r *Retry) scaledAttempts(attempts int) int {
+	if r.priority == PriorityInteractive || attempts == Unlimited {
+		return attempts
+	}
+
+	factor, _ := r.scaler()(r.priority)
+	scaled := int(float64(attempts) * factor)
+	if scaled < 1 {
+		return 1
+	}
+	return scaled
+}
+
+// scaledDelay returns delay after err scaled by the priority,
+// delays requested by RetryAfter are not scaled
+func (r Retry) scaledDelay(delay time.Duration, err error) time.Duration {
+	if r.priority == PriorityInteractive {
+		return delay
+	}
+	if _, requested := requestedDelay(err); requested {
+		return delay
+	}
+
+	_, factor := r.scaler()(r.priority)
+	if scaled := float64(delay) * factor; scaled < float64(backoff.MaxDelay) {
+		return time.Duration(scaled)
+	}
+	return backoff.MaxDelay
+}
+
+// scaler returns PriorityScaler of Retry
+func (r Retry) scaler() PriorityScaler {
+	if r.priorityScaler == nil {
+		return DefaultPriorityScaler
+	}
+	return r.priorityScaler
+}
@@ -0,0 +1,29 @@
+package retry
+
+import "context"
+
+// PolicyProvider resolves the policy of a call, e.g. by the tenant
+// or the endpoint of the request carried in ctx, so retries are tuned
+// from a single integration point. Retry is PolicyProvider of itself.
+// PolicyProvider must be safe for concurrent use.
+type PolicyProvider interface {
+	PolicyFor(ctx context.Context) Retry
+}
+
+// PolicyProviderFunc adapts a function to PolicyProvider.
+type PolicyProviderFunc func(ctx context.Context) Retry
+
+// PolicyFor calls f.
+func (f PolicyProviderFunc) PolicyFor(ctx context.Context) Retry {
+	return f(ctx)
+}
+
+// PolicyFor returns Retry itself.
+func (r Retry) PolicyFor(context.Context) Retry {
+	return r
+}
+
+// DoFor works same as Retry.Do with the policy resolved by provider for ctx.
+func DoFor(ctx context.Context, provider PolicyProvider, call Func) error {
+	return provider.PolicyFor(ctx).Do(ctx, call)
+}
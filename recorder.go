@@ -0,0 +1,80 @@
+package retry
+
+import (
+	"context"
+	"time"
+)
+
+// Recorder observes attempts of Do, it is the single hook implemented
+// by metrics and logging adapters. Recorder must be safe for concurrent use.
+type Recorder interface {
+	// AttemptStarted is called before the zero-based attempt.
+	AttemptStarted(ctx context.Context, attempt int)
+	// AttemptEnded is called after the zero-based attempt
+	// with its error and duration.
+	AttemptEnded(ctx context.Context, attempt int, err error, duration time.Duration)
+	// Exhausted is called when Do gives up retrying a temporary error err
+	// after attempts: no attempts left or retry is declined.
+	Exhausted(ctx context.Context, attempts int, err error)
+}
+
+// DelayRecorder is implemented by Recorder observing backoff sleeps,
+// e.g. to verify jitter spreads retries as intended.
+type DelayRecorder interface {
+	// Slept is called after the backoff sleep following the zero-based
+	// attempt with the time actually slept, after jitter and adjustments
+	// such as alignment and pacing.
+	Slept(ctx context.Context, attempt int, slept time.Duration)
+}
+
+// Recorders combines recorders into one, nil recorders are skipped.
+func Recorders(recorders ...Recorder) Recorder {
+	var combined multiRecorder
+	for _, recorder := range recorders {
+		switch recorder := recorder.(type) {
+		case nil:
+		case multiRecorder:
+			combined = append(combined, recorder...)
+		default:
+			combined = append(combined, recorder)
+		}
+	}
+
+	switch len(combined) {
+	case 0:
+		return nil
+	case 1:
+		return combined[0]
+	default:
+		return combined
+	}
+}
+
+// multiRecorder notifies recorders in order
+type multiRecorder []Recorder
+
+func (m multiRecorder) AttemptStarted(ctx context.Context, attempt int) {
+	for _, recorder := range m {
+		recorder.AttemptStarted(ctx, attempt)
+	}
+}
+
+func (m multiRecorder) AttemptEnded(ctx context.Context, attempt int, err error, duration time.Duration) {
+	for _, recorder := range m {
+		recorder.AttemptEnded(ctx, attempt, err, duration)
+	}
+}
+
+func (m multiRecorder) Exhausted(ctx context.Context, attempts int, err error) {
+	for _, recorder := range m {
+		recorder.Exhausted(ctx, attempts, err)
+	}
+}
+
+func (m multiRecorder) Slept(ctx context.Context, attempt int, slept time.Duration) {
+	for _, recorder := range m {
+		if delays, ok := recorder.(DelayRecorder); ok {
+			delays.Slept(ctx, attempt, slept)
+		}
+	}
+}
@@ -3,13 +3,21 @@ package retry
 import (
 	"context"
 	"fmt"
-	"math/rand"
+	"io"
+	"math"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/osvim/retry/backoff"
 )
 
 const DefaultJitter float64 = 0.1
 
+// Unlimited is the number of attempts of Forever policy.
+const Unlimited = math.MaxInt
+
 // Func is a retryable function.
 // Should return (false, nil) when success,
 // (true, error) when error is temporary,
@@ -18,11 +26,7 @@ type Func func() (retry bool, err error)
 
 // Do works same as Retry.Do
 func Do(ctx context.Context, call Func, opts ...Option) error {
-	var cfg Config
-	for _, opt := range opts {
-		opt(&cfg)
-	}
-	return New(cfg).Do(ctx, call)
+	return New(Config{}.With(opts...)).Do(ctx, call)
 }
 
 // Option configures Retry
@@ -32,6 +36,7 @@ type Option func(*Config)
 func WithAttempts(attempts int) Option {
 	return func(cfg *Config) {
 		cfg.Attempts = attempts
+		cfg.set |= setAttempts
 	}
 }
 
@@ -39,6 +44,7 @@ func WithAttempts(attempts int) Option {
 func WithBackoff(duration time.Duration) Option {
 	return func(cfg *Config) {
 		cfg.Backoff = duration
+		cfg.set |= setBackoff
 	}
 }
 
@@ -47,6 +53,16 @@ func WithBackoff(duration time.Duration) Option {
 func WithExponential() Option {
 	return func(cfg *Config) {
 		cfg.Exponential = true
+		cfg.set |= setExponential
+	}
+}
+
+// WithMultiplier sets the growth factor of exponential backoff,
+// see Config.Multiplier
+func WithMultiplier(multiplier float64) Option {
+	return func(cfg *Config) {
+		cfg.Multiplier = multiplier
+		cfg.set |= setMultiplier
 	}
 }
 
@@ -55,6 +71,366 @@ func WithExponential() Option {
 func WithJitter(jitter float64) Option {
 	return func(cfg *Config) {
 		cfg.Jitter = jitter
+		cfg.set |= setJitter
+	}
+}
+
+// WithDecorrelated makes backoff decorrelated,
+// see Config.Decorrelated
+func WithDecorrelated() Option {
+	return func(cfg *Config) {
+		cfg.Decorrelated = true
+		cfg.set |= setDecorrelated
+	}
+}
+
+// WithLogarithmic makes backoff logarithmic,
+// see Config.Logarithmic
+func WithLogarithmic() Option {
+	return func(cfg *Config) {
+		cfg.Logarithmic = true
+		cfg.set |= setLogarithmic
+	}
+}
+
+// WithMaxBackoff caps backoff, see Config.MaxBackoff
+func WithMaxBackoff(duration time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.MaxBackoff = duration
+		cfg.set |= setMaxBackoff
+	}
+}
+
+// WithRetryProbability retries failed Func calls with probability p,
+// see Config.RetryProbability
+func WithRetryProbability(p float64) Option {
+	return func(cfg *Config) {
+		cfg.RetryProbability = p
+		cfg.set |= setRetryProbability
+	}
+}
+
+// WithRetryIf sets Classifier of errors, see Config.RetryIf
+func WithRetryIf(classifier Classifier) Option {
+	return func(cfg *Config) {
+		cfg.RetryIf = classifier
+		cfg.set |= setRetryIf
+	}
+}
+
+// WithAttemptTimeout limits the duration of each ContextFunc call,
+// see Config.AttemptTimeout
+func WithAttemptTimeout(timeout time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.AttemptTimeout = timeout
+		cfg.set |= setAttemptTimeout
+	}
+}
+
+// WithProgressiveTimeout doubles the attempt timeout after each attempt,
+// see Config.ProgressiveTimeout
+func WithProgressiveTimeout() Option {
+	return func(cfg *Config) {
+		cfg.ProgressiveTimeout = true
+		cfg.set |= setProgressiveTimeout
+	}
+}
+
+// WithDeadlineSpread divides the remaining deadline evenly
+// across the remaining attempts, see Config.DeadlineSpread
+func WithDeadlineSpread() Option {
+	return func(cfg *Config) {
+		cfg.DeadlineSpread = true
+		cfg.set |= setDeadlineSpread
+	}
+}
+
+// WithRateLimiter limits the rate of attempts by limiter,
+// see Config.RateLimiter
+func WithRateLimiter(limiter *AdaptiveRateLimiter, throttled Classifier) Option {
+	return func(cfg *Config) {
+		cfg.RateLimiter, cfg.Throttled = limiter, throttled
+		cfg.set |= setRateLimiter
+	}
+}
+
+// WithExplain writes the trace of decisions of Do to w,
+// see Config.Explain
+func WithExplain(w io.Writer) Option {
+	return func(cfg *Config) {
+		cfg.Explain = w
+		cfg.set |= setExplain
+	}
+}
+
+// WithRecorder attaches recorders observing attempts,
+// see Config.Recorder
+func WithRecorder(recorders ...Recorder) Option {
+	return func(cfg *Config) {
+		cfg.Recorder = Recorders(append([]Recorder{cfg.Recorder}, recorders...)...)
+		cfg.set |= setRecorder
+	}
+}
+
+// WithHeartbeat calls heartbeat every interval of backoff sleeps,
+// see Config.Heartbeat
+func WithHeartbeat(interval time.Duration, heartbeat func(remaining time.Duration)) Option {
+	return func(cfg *Config) {
+		cfg.HeartbeatInterval, cfg.Heartbeat = interval, heartbeat
+		cfg.set |= setHeartbeat
+	}
+}
+
+// WithWake interrupts backoff sleeps by wake signals, see Config.Wake
+func WithWake(wake <-chan struct{}) Option {
+	return func(cfg *Config) {
+		cfg.Wake = wake
+		cfg.set |= setWake
+	}
+}
+
+// WithPacing spaces retries by a leaky bucket, see Config.Pacing
+func WithPacing(retriesPerSecond float64) Option {
+	return func(cfg *Config) {
+		cfg.Pacing = retriesPerSecond
+		cfg.set |= setPacing
+	}
+}
+
+// WithOnExhausted calls onExhausted when Do gives up,
+// see Config.OnExhausted
+func WithOnExhausted(onExhausted func(ctx context.Context, attempts int, lastErr error)) Option {
+	return func(cfg *Config) {
+		cfg.OnExhausted = onExhausted
+		cfg.set |= setOnExhausted
+	}
+}
+
+// WithOnRetryableFailure calls onFailure after each retryable failure,
+// see Config.OnRetryableFailure
+func WithOnRetryableFailure(onFailure func(err error, resource interface{})) Option {
+	return func(cfg *Config) {
+		cfg.OnRetryableFailure = onFailure
+		cfg.set |= setOnRetryableFailure
+	}
+}
+
+// WithClock sets the source of time, see Config.Clock
+func WithClock(clock Clock) Option {
+	return func(cfg *Config) {
+		cfg.Clock = clock
+		cfg.set |= setClock
+	}
+}
+
+// WithMaxRetriesPer limits retries to n per window, see Config.MaxRetries
+func WithMaxRetriesPer(window time.Duration, n int) Option {
+	return func(cfg *Config) {
+		cfg.MaxRetriesWindow, cfg.MaxRetries = window, n
+		cfg.set |= setMaxRetries
+	}
+}
+
+// WithAlignment aligns retries to wall clock boundaries, see Config.Alignment
+func WithAlignment(boundary time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.Alignment = boundary
+		cfg.set |= setAlignment
+	}
+}
+
+// WithHours retries only within hours, see Config.Hours
+func WithHours(hours backoff.Hours) Option {
+	return func(cfg *Config) {
+		cfg.Hours = &hours
+		cfg.set |= setHours
+	}
+}
+
+// WithCoordinator shares backoff among replicas, see Config.Coordinator
+func WithCoordinator(coordinator Coordinator) Option {
+	return func(cfg *Config) {
+		cfg.Coordinator = coordinator
+		cfg.set |= setCoordinator
+	}
+}
+
+// WithGate allows attempts only when gate is open, see Config.Gate
+func WithGate(gate Gate) Option {
+	return func(cfg *Config) {
+		cfg.Gate = gate
+		cfg.set |= setGate
+	}
+}
+
+// WithLocker holds mu during each attempt, see Config.Locker
+func WithLocker(mu sync.Locker) Option {
+	return func(cfg *Config) {
+		cfg.Locker = mu
+		cfg.set |= setLocker
+	}
+}
+
+// WithAdaptiveAttempts shrinks attempts during outages,
+// see Config.AdaptiveAttempts
+func WithAdaptiveAttempts(adaptive *AdaptiveAttempts) Option {
+	return func(cfg *Config) {
+		cfg.AdaptiveAttempts = adaptive
+		cfg.set |= setAdaptiveAttempts
+	}
+}
+
+// WithAttemptsByClass limits retries per error class,
+// see Config.AttemptsByClass
+func WithAttemptsByClass(classOf ClassOf, retries map[Class]int) Option {
+	return func(cfg *Config) {
+		cfg.ClassOf, cfg.AttemptsByClass = classOf, retries
+		cfg.set |= setAttemptsByClass
+	}
+}
+
+// WithSwitch routes failures to policies by the class of the error,
+// see Config.Policies
+func WithSwitch(classOf ClassOf, policies map[Class]Retry) Option {
+	return func(cfg *Config) {
+		cfg.SwitchClassOf, cfg.Policies = classOf, policies
+		cfg.set |= setPolicies
+	}
+}
+
+// WithThen continues with policies when attempts are over, see Config.Then
+func WithThen(policies ...Retry) Option {
+	return func(cfg *Config) {
+		cfg.Then = policies
+		cfg.set |= setThen
+	}
+}
+
+// WithPriority scales the policy for work of priority p, see Config.Priority
+func WithPriority(p Priority) Option {
+	return func(cfg *Config) {
+		cfg.Priority = p
+		cfg.set |= setPriority
+	}
+}
+
+// WithPriorityScaler sets the scaler of priorities, see Config.PriorityScaler
+func WithPriorityScaler(scaler PriorityScaler) Option {
+	return func(cfg *Config) {
+		cfg.PriorityScaler = scaler
+		cfg.set |= setPriorityScaler
+	}
+}
+
+// WithEnabled toggles retries by a feature flag, see Config.Enabled
+func WithEnabled(enabled func(ctx context.Context) bool) Option {
+	return func(cfg *Config) {
+		cfg.Enabled = enabled
+		cfg.set |= setEnabled
+	}
+}
+
+// WithExhaustedError sets the constructor of the error returned
+// when attempts are over, see Config.ExhaustedError
+func WithExhaustedError(exhaustedError func(attempts int, lastErr error) error) Option {
+	return func(cfg *Config) {
+		cfg.ExhaustedError = exhaustedError
+		cfg.set |= setExhaustedError
+	}
+}
+
+// WithSoftDeadline stops starting new attempts after d, see Config.SoftDeadline
+func WithSoftDeadline(d time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.SoftDeadline = d
+		cfg.set |= setSoftDeadline
+	}
+}
+
+// WithInterruptible runs each attempt in its own goroutine,
+// see Config.Interruptible
+func WithInterruptible(onOrphaned func(err error)) Option {
+	return func(cfg *Config) {
+		cfg.Interruptible, cfg.OnOrphaned = true, onOrphaned
+		cfg.set |= setInterruptible
+	}
+}
+
+// WithStopSignals stops retrying on the first of signals, see Config.StopSignals
+func WithStopSignals(signals ...os.Signal) Option {
+	return func(cfg *Config) {
+		cfg.StopSignals = signals
+		cfg.set |= setStopSignals
+	}
+}
+
+// WithDeterministic makes randomized decisions reproducible from seed,
+// see Config.Deterministic
+func WithDeterministic(seed int64) Option {
+	return func(cfg *Config) {
+		cfg.Deterministic, cfg.Seed = true, seed
+		cfg.set |= setDeterministic
+	}
+}
+
+// WithRetryOnDeadline toggles retries of attempts exceeding their deadline,
+// see Config.RetryOnDeadline
+func WithRetryOnDeadline(retry bool) Option {
+	return func(cfg *Config) {
+		cfg.RetryOnDeadline = &retry
+		cfg.set |= setRetryOnDeadline
+	}
+}
+
+// WithMinBackoff floors backoff, see Config.MinBackoff
+func WithMinBackoff(duration time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.MinBackoff = duration
+		cfg.set |= setMinBackoff
+	}
+}
+
+// WithExactFirstRetry makes the first retry after the exact backoff,
+// see Config.ExactFirstRetry
+func WithExactFirstRetry() Option {
+	return func(cfg *Config) {
+		cfg.ExactFirstRetry = true
+		cfg.set |= setExactFirstRetry
+	}
+}
+
+// WithImmediateRetries makes the first n retries without delay,
+// see Config.ImmediateRetries
+func WithImmediateRetries(n int) Option {
+	return func(cfg *Config) {
+		cfg.ImmediateRetries = n
+		cfg.set |= setImmediateRetries
+	}
+}
+
+// WithJitterDuration applies jitter of an absolute duration to backoff,
+// see Config.JitterDuration
+func WithJitterDuration(jitter time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.JitterDuration = jitter
+		cfg.set |= setJitterDuration
+	}
+}
+
+// WithJitterSeed makes the jitter of the policy reproducible from seed,
+// see Config.JitterSeed
+func WithJitterSeed(seed int64) Option {
+	return func(cfg *Config) {
+		cfg.JitterSeed = &seed
+		cfg.set |= setJitterSeed
+	}
+}
+
+// WithConfig merges override over the policy, see Config.Merge,
+// e.g. to apply a policy loaded from a file over local classifiers and hooks.
+func WithConfig(override Config) Option {
+	return func(cfg *Config) {
+		*cfg = cfg.Merge(override)
 	}
 }
 
@@ -66,17 +442,487 @@ type Config struct {
 	// Exponential makes Backoff exponential:
 	// backoff multiplied 2 raised to the current attempt.
 	Exponential bool
+	// Multiplier replaces 2 as the growth factor of exponential backoff,
+	// if positive, e.g. 1.6 of gRPC connection backoff.
+	Multiplier float64
 	// Jitter applies jitter to backoff, expected to be in range [0.0, 1.0).
 	// If the passed value out of the range, DefaultJitter is used.
 	Jitter float64
+	// Decorrelated makes Backoff decorrelated, it takes precedence over Exponential:
+	// the delay is random between Backoff and 3 times the previous delay.
+	Decorrelated bool
+	// Logarithmic makes Backoff logarithmic, it takes precedence over Exponential:
+	// backoff multiplied by the binary logarithm of the current attempt plus 2,
+	// so the delay grows but flattens quickly.
+	Logarithmic bool
+	// MaxBackoff caps the delay after failed Func call, including jitter.
+	MaxBackoff time.Duration
+	// RetryProbability is the probability of retrying a failed Func call,
+	// expected to be in range (0.0, 1.0). Retrying only a fraction of failures
+	// sheds load when many clients share one struggling backend.
+	// If the value out of the range, every failure is retried.
+	RetryProbability float64
+	// RetryIf classifies errors of functions returning just an error,
+	// e.g. DoTyped, every error is retried if nil.
+	RetryIf Classifier
+	// AttemptTimeout limits the duration of each ContextFunc call,
+	// the context of the call is cancelled when it is exceeded.
+	AttemptTimeout time.Duration
+	// ProgressiveTimeout doubles AttemptTimeout after each attempt,
+	// e.g. 1s, 2s, 4s, so early attempts fail fast as probes,
+	// while the last attempt is given the most time.
+	ProgressiveTimeout bool
+	// DeadlineSpread limits the duration of each ContextFunc call with
+	// the remaining deadline of the context divided by the remaining attempts,
+	// so early attempts can't starve later ones of time.
+	DeadlineSpread bool
+	// RateLimiter limits the rate of attempts, adapting to throttling errors
+	// classified by Throttled, OnThrottling is used if it is nil.
+	// The limiter is meant to be shared by all calls to one service.
+	RateLimiter *AdaptiveRateLimiter
+	Throttled   Classifier
+	// Explain is the writer of the trace of decisions made by Do,
+	// e.g. "attempt 3 failed: connection refused; retryable, sleeping 840ms (base 800ms, jitter +5%)",
+	// to debug misbehaving policies.
+	Explain io.Writer
+	// Recorder observes attempts, e.g. to export metrics,
+	// see Recorders to attach several ones.
+	Recorder Recorder
+	// Heartbeat is called every HeartbeatInterval of backoff sleeps with
+	// the remaining time of the sleep, so supervisors and liveness probes can see
+	// the worker is intentionally sleeping rather than hung.
+	Heartbeat         func(remaining time.Duration)
+	HeartbeatInterval time.Duration
+	// Wake interrupts the current backoff sleep, so Func is called immediately,
+	// e.g. on config change or dependency healthy event.
+	// A closed channel disables backoff at all.
+	Wake <-chan struct{}
+	// Pacing is the max number of retries per second of all Do calls of Retry,
+	// retries are spaced evenly by a leaky bucket, smoothing retry bursts
+	// after mass failures. Every Retry created by New has its own bucket.
+	Pacing float64
+	// OnExhausted is called when Do gives up retrying a temporary error
+	// after attempts, so alerting and cleanup run even when the caller
+	// swallows or transforms the returned error.
+	OnExhausted func(ctx context.Context, attempts int, lastErr error)
+	// OnRetryableFailure is called after each attempt failed with a temporary
+	// error, with the resource attached to the error by FailedOn, if any,
+	// e.g. so a connection pool can evict a bad connection before
+	// the next attempt picks a fresh one.
+	OnRetryableFailure func(err error, resource interface{})
+	// Clock is the source of time of attempt durations, deadlines
+	// and backoff sleeps, SystemClock if nil, e.g. a fake clock in tests.
+	Clock Clock
+	// MaxRetries is the max number of retries of all Do calls of Retry
+	// per sliding MaxRetriesWindow, e.g. to respect a partner API quota:
+	// bursts of quick retries are allowed, but a retry exceeding the limit
+	// is delayed until the window of the n-th previous retry is over.
+	// Every Retry created by New has its own window.
+	MaxRetries       int
+	MaxRetriesWindow time.Duration
+	// Alignment extends the delay after failed Func call, so the retry
+	// happens at the next wall clock boundary, a multiple of Alignment,
+	// e.g. time.Minute for upstream quotas reset at the top of a minute.
+	Alignment time.Duration
+	// Hours are the time windows of retries, e.g. business hours of a partner
+	// system down overnight: a retry due outside of the window is delayed
+	// until the window opens. Retries are not limited if nil.
+	Hours *backoff.Hours
+	// Coordinator shares the consecutive failures among replicas,
+	// so exponential and linear backoff continue from the failures
+	// of all replicas retrying the same dependency.
+	Coordinator Coordinator
+	// Gate is checked before each attempt, Do returns ErrGateClosed
+	// when the gate denies it, e.g. when the process is not the leader
+	// of a singleton task or has lost the leadership while retrying.
+	Gate Gate
+	// Locker is locked for the duration of each Func call and unlocked
+	// during backoff sleeps, so a lock protecting the call is not held
+	// while sleeping.
+	Locker sync.Locker
+	// AdaptiveAttempts shrinks Attempts while the recent failure rate
+	// is high, so calls fail fast during confirmed outages.
+	// It is meant to be shared by all calls to one dependency.
+	AdaptiveAttempts *AdaptiveAttempts
+	// AttemptsByClass is the max number of retries of errors per class
+	// returned by ClassOf, e.g. 5 for timeouts, 2 for throttling and
+	// 0 for auth errors. Errors of classes out of the map are retried
+	// up to Attempts, which limits the total number of calls anyway.
+	AttemptsByClass map[Class]int
+	ClassOf         ClassOf
+	// Policies route retryable failures by the class returned by
+	// SwitchClassOf: the attempts and the backoff after a failure are taken
+	// from the policy of its class, see Switch.
+	Policies      map[Class]Retry
+	SwitchClassOf ClassOf
+	// Then are the policies continuing one another when Attempts are over,
+	// each makes up to its attempts more Func calls with its backoff,
+	// see Retry.Then.
+	Then []Retry
+	// Priority is the priority of the retried work, lower priorities get
+	// fewer attempts and longer backoff than interactive requests sharing
+	// the same base policy, as scaled by PriorityScaler,
+	// DefaultPriorityScaler if nil.
+	Priority       Priority
+	PriorityScaler PriorityScaler
+	// Enabled is evaluated before each retry, the error of the failed
+	// Func call is returned when it reports false, so retries can be
+	// toggled per environment or experiment by any feature flag system.
+	Enabled func(ctx context.Context) bool
+	// ExhaustedError constructs the error returned by Do when attempts
+	// are over instead of "no attempts left: <last error>", e.g. to match
+	// an error taxonomy or to hide sensitive messages of the last error.
+	// The error should wrap lastErr to keep it matched by errors.Is.
+	ExhaustedError func(attempts int, lastErr error) error
+	// SoftDeadline is the duration of Do after which no new attempts are
+	// started, unlike the deadline of the context it lets the in-flight
+	// attempt finish: Do gives up instead of a retry which would start
+	// past the soft deadline, so a fallback still fits the hard deadline.
+	SoftDeadline time.Duration
+	// Interruptible runs each Func call in its own goroutine, so Do returns
+	// the error of the context as soon as it is done, even if Func blocks
+	// ignoring cancellation. Only the context passed to Do is watched:
+	// AttemptTimeout cancels the context of a ContextFunc call, but doesn't
+	// interrupt a call ignoring it. A panic of Func is raised again by the
+	// goroutine of Do. The result of the abandoned call is passed to
+	// OnOrphaned, if set, when the call returns, a panic as an error.
+	Interruptible bool
+	OnOrphaned    func(err error)
+	// StopSignals stop Do on the first of the process signals, e.g.
+	// os.Interrupt and syscall.SIGTERM, for graceful shutdown: the current
+	// attempt is finished, but no attempt is started after the signal
+	// and backoff sleeps are interrupted, Do returns ErrStopped.
+	StopSignals []os.Signal
+	// Deterministic makes jitter, decorrelated backoff and RetryProbability
+	// of every Do call reproducible from Seed, so a failure found by a fuzzer
+	// or logged in production with its seed can be replayed locally.
+	Deterministic bool
+	Seed          int64
+	// RetryOnDeadline decides on ContextFunc errors matching
+	// context.DeadlineExceeded instead of their classifier, if set:
+	// true retries attempts exceeding their own timeout, e.g. AttemptTimeout,
+	// while the deadline of the context passed to Do is never retried,
+	// false retries neither.
+	RetryOnDeadline *bool
+	// MinBackoff floors the delay after failed Func call, so jitter never
+	// makes it shorter, e.g. when upstream enforces a minimum spacing
+	// between calls. It takes precedence over MaxBackoff.
+	MinBackoff time.Duration
+	// ExactFirstRetry makes the first retry after the backoff without jitter,
+	// so the recovery from a blip is fast and predictable, jitter and
+	// decorrelated backoff spread the later retries.
+	ExactFirstRetry bool
+	// ImmediateRetries is the number of the first retries made back-to-back,
+	// which handle one-off blips, the backoff starts after them.
+	ImmediateRetries int
+	// JitterDuration applies jitter of an absolute duration to backoff:
+	// the delay is random within ±JitterDuration around the backoff, for
+	// short backoffs a fraction of which is too little to spread retries.
+	// It takes precedence over Jitter, decorrelated backoff is not affected.
+	JitterDuration time.Duration
+	// JitterSeed seeds the sequence of jitter and decorrelated backoff
+	// of the policy, if set, so it is reproducible across runs independently
+	// of the global random numbers, e.g. in integration tests or benchmarks.
+	// Unlike Deterministic, Do calls continue the sequence of the policy
+	// instead of repeating it.
+	JitterSeed *int64
+
+	// set marks fields explicitly set by Option, see Config.Merge.
+	set fields
+}
+
+// fields is a set of Config fields.
+type fields uint
+
+const (
+	setAttempts fields = 1 << iota
+	setBackoff
+	setExponential
+	setJitter
+	setDecorrelated
+	setMaxBackoff
+	setRetryProbability
+	setRetryIf
+	setAttemptTimeout
+	setDeadlineSpread
+	setProgressiveTimeout
+	setRateLimiter
+	setExplain
+	setRecorder
+	setHeartbeat
+	setWake
+	setPacing
+	setOnExhausted
+	setOnRetryableFailure
+	setClock
+	setMaxRetries
+	setAlignment
+	setHours
+	setCoordinator
+	setGate
+	setLocker
+	setAdaptiveAttempts
+	setAttemptsByClass
+	setPolicies
+	setThen
+	setPriority
+	setPriorityScaler
+	setEnabled
+	setExhaustedError
+	setSoftDeadline
+	setInterruptible
+	setStopSignals
+	setDeterministic
+	setRetryOnDeadline
+	setMinBackoff
+	setExactFirstRetry
+	setImmediateRetries
+	setLogarithmic
+	setJitterDuration
+	setJitterSeed
+	setMultiplier
+)
+
+// Merge returns a copy of Config with fields overridden by override.
+// A field of override takes effect when it is non-zero
+// or explicitly set by Option, so zero values can be passed as
+//
+//	cfg.Merge(retry.Config{}.With(retry.WithJitter(0)))
+func (cfg Config) Merge(override Config) Config {
+	if override.Attempts != 0 || override.set&setAttempts != 0 {
+		cfg.Attempts = override.Attempts
+	}
+	if override.Backoff != 0 || override.set&setBackoff != 0 {
+		cfg.Backoff = override.Backoff
+	}
+	if override.Exponential || override.set&setExponential != 0 {
+		cfg.Exponential = override.Exponential
+	}
+	if override.Jitter != 0 || override.set&setJitter != 0 {
+		cfg.Jitter = override.Jitter
+	}
+	if override.Decorrelated || override.set&setDecorrelated != 0 {
+		cfg.Decorrelated = override.Decorrelated
+	}
+	if override.Logarithmic || override.set&setLogarithmic != 0 {
+		cfg.Logarithmic = override.Logarithmic
+	}
+	if override.MaxBackoff != 0 || override.set&setMaxBackoff != 0 {
+		cfg.MaxBackoff = override.MaxBackoff
+	}
+	if override.RetryProbability != 0 || override.set&setRetryProbability != 0 {
+		cfg.RetryProbability = override.RetryProbability
+	}
+	if override.RetryIf != nil || override.set&setRetryIf != 0 {
+		cfg.RetryIf = override.RetryIf
+	}
+	if override.AttemptTimeout != 0 || override.set&setAttemptTimeout != 0 {
+		cfg.AttemptTimeout = override.AttemptTimeout
+	}
+	if override.ProgressiveTimeout || override.set&setProgressiveTimeout != 0 {
+		cfg.ProgressiveTimeout = override.ProgressiveTimeout
+	}
+	if override.DeadlineSpread || override.set&setDeadlineSpread != 0 {
+		cfg.DeadlineSpread = override.DeadlineSpread
+	}
+	if override.RateLimiter != nil || override.set&setRateLimiter != 0 {
+		cfg.RateLimiter, cfg.Throttled = override.RateLimiter, override.Throttled
+	}
+	if override.Explain != nil || override.set&setExplain != 0 {
+		cfg.Explain = override.Explain
+	}
+	if override.Recorder != nil || override.set&setRecorder != 0 {
+		cfg.Recorder = override.Recorder
+	}
+	if override.Heartbeat != nil || override.set&setHeartbeat != 0 {
+		cfg.HeartbeatInterval, cfg.Heartbeat = override.HeartbeatInterval, override.Heartbeat
+	}
+	if override.Wake != nil || override.set&setWake != 0 {
+		cfg.Wake = override.Wake
+	}
+	if override.Pacing != 0 || override.set&setPacing != 0 {
+		cfg.Pacing = override.Pacing
+	}
+	if override.OnExhausted != nil || override.set&setOnExhausted != 0 {
+		cfg.OnExhausted = override.OnExhausted
+	}
+	if override.OnRetryableFailure != nil || override.set&setOnRetryableFailure != 0 {
+		cfg.OnRetryableFailure = override.OnRetryableFailure
+	}
+	if override.Clock != nil || override.set&setClock != 0 {
+		cfg.Clock = override.Clock
+	}
+	if override.MaxRetries != 0 || override.set&setMaxRetries != 0 {
+		cfg.MaxRetriesWindow, cfg.MaxRetries = override.MaxRetriesWindow, override.MaxRetries
+	}
+	if override.Alignment != 0 || override.set&setAlignment != 0 {
+		cfg.Alignment = override.Alignment
+	}
+	if override.Hours != nil || override.set&setHours != 0 {
+		cfg.Hours = override.Hours
+	}
+	if override.Coordinator != nil || override.set&setCoordinator != 0 {
+		cfg.Coordinator = override.Coordinator
+	}
+	if override.Gate != nil || override.set&setGate != 0 {
+		cfg.Gate = override.Gate
+	}
+	if override.Locker != nil || override.set&setLocker != 0 {
+		cfg.Locker = override.Locker
+	}
+	if override.AdaptiveAttempts != nil || override.set&setAdaptiveAttempts != 0 {
+		cfg.AdaptiveAttempts = override.AdaptiveAttempts
+	}
+	if override.AttemptsByClass != nil || override.set&setAttemptsByClass != 0 {
+		cfg.ClassOf, cfg.AttemptsByClass = override.ClassOf, override.AttemptsByClass
+	}
+	if override.Policies != nil || override.set&setPolicies != 0 {
+		cfg.SwitchClassOf, cfg.Policies = override.SwitchClassOf, override.Policies
+	}
+	if override.Then != nil || override.set&setThen != 0 {
+		cfg.Then = override.Then
+	}
+	if override.Priority != 0 || override.set&setPriority != 0 {
+		cfg.Priority = override.Priority
+	}
+	if override.PriorityScaler != nil || override.set&setPriorityScaler != 0 {
+		cfg.PriorityScaler = override.PriorityScaler
+	}
+	if override.Enabled != nil || override.set&setEnabled != 0 {
+		cfg.Enabled = override.Enabled
+	}
+	if override.ExhaustedError != nil || override.set&setExhaustedError != 0 {
+		cfg.ExhaustedError = override.ExhaustedError
+	}
+	if override.SoftDeadline != 0 || override.set&setSoftDeadline != 0 {
+		cfg.SoftDeadline = override.SoftDeadline
+	}
+	if override.Interruptible || override.set&setInterruptible != 0 {
+		cfg.Interruptible, cfg.OnOrphaned = override.Interruptible, override.OnOrphaned
+	}
+	if override.StopSignals != nil || override.set&setStopSignals != 0 {
+		cfg.StopSignals = override.StopSignals
+	}
+	if override.Deterministic || override.set&setDeterministic != 0 {
+		cfg.Deterministic, cfg.Seed = override.Deterministic, override.Seed
+	}
+	if override.RetryOnDeadline != nil || override.set&setRetryOnDeadline != 0 {
+		cfg.RetryOnDeadline = override.RetryOnDeadline
+	}
+	if override.MinBackoff != 0 || override.set&setMinBackoff != 0 {
+		cfg.MinBackoff = override.MinBackoff
+	}
+	if override.ExactFirstRetry || override.set&setExactFirstRetry != 0 {
+		cfg.ExactFirstRetry = override.ExactFirstRetry
+	}
+	if override.ImmediateRetries != 0 || override.set&setImmediateRetries != 0 {
+		cfg.ImmediateRetries = override.ImmediateRetries
+	}
+	if override.JitterDuration != 0 || override.set&setJitterDuration != 0 {
+		cfg.JitterDuration = override.JitterDuration
+	}
+	if override.JitterSeed != nil || override.set&setJitterSeed != 0 {
+		cfg.JitterSeed = override.JitterSeed
+	}
+	if override.Multiplier != 0 || override.set&setMultiplier != 0 {
+		cfg.Multiplier = override.Multiplier
+	}
+	cfg.set |= override.set
+	return cfg
+}
+
+// With returns a copy of Config with opts applied.
+func (cfg Config) With(opts ...Option) Config {
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// NewPolicy returns Retry configured by opts, it is the same as
+//
+//	retry.New(retry.Config{}.With(opts...))
+func NewPolicy(opts ...Option) Retry {
+	return New(Config{}.With(opts...))
 }
 
+// FromConfig returns Retry of cfg adjusted by opts, so a policy loaded
+// as Config can be extended by options, e.g. classifiers and hooks
+// which can't be loaded from a file.
+func FromConfig(cfg Config, opts ...Option) Retry {
+	return New(cfg.With(opts...))
+}
+
+// New returns Retry of cfg, zero fields of cfg keep their defaults.
 func New(cfg Config) Retry {
-	r := Attempts(cfg.Attempts)
-	if cfg.Exponential {
+	r := Attempts(cfg.Attempts).
+		MaxBackoff(cfg.MaxBackoff).
+		MinBackoff(cfg.MinBackoff).
+		ImmediateRetries(cfg.ImmediateRetries).
+		JitterDuration(cfg.JitterDuration).
+		Multiplier(cfg.Multiplier).
+		RetryProbability(cfg.RetryProbability).
+		RetryIf(cfg.RetryIf).
+		AttemptTimeout(cfg.AttemptTimeout).
+		RateLimiter(cfg.RateLimiter, cfg.Throttled).
+		Explain(cfg.Explain).
+		Recorder(cfg.Recorder).
+		Heartbeat(cfg.HeartbeatInterval, cfg.Heartbeat).
+		Wake(cfg.Wake).
+		Pacing(cfg.Pacing).
+		OnExhausted(cfg.OnExhausted).
+		OnRetryableFailure(cfg.OnRetryableFailure).
+		Clock(cfg.Clock).
+		MaxRetriesPer(cfg.MaxRetriesWindow, cfg.MaxRetries).
+		Aligned(cfg.Alignment).
+		Within(cfg.Hours).
+		Coordinator(cfg.Coordinator).
+		Gate(cfg.Gate).
+		AdaptiveAttempts(cfg.AdaptiveAttempts).
+		AttemptsByClass(cfg.ClassOf, cfg.AttemptsByClass).
+		Priority(cfg.Priority, cfg.PriorityScaler).
+		Enabled(cfg.Enabled).
+		ExhaustedError(cfg.ExhaustedError).
+		SoftDeadline(cfg.SoftDeadline).
+		StopSignals(cfg.StopSignals...)
+	r = Locked(cfg.Locker, r)
+	if cfg.Policies != nil && cfg.SwitchClassOf != nil {
+		r = Switch(cfg.SwitchClassOf, cfg.Policies, r)
+	}
+	for _, next := range cfg.Then {
+		r = r.Then(next)
+	}
+	if cfg.DeadlineSpread {
+		r = r.DeadlineSpread()
+	}
+	if cfg.ProgressiveTimeout {
+		r = r.ProgressiveTimeout()
+	}
+	if cfg.Interruptible {
+		r = r.Interruptible(cfg.OnOrphaned)
+	}
+	if cfg.Deterministic {
+		r = r.Deterministic(cfg.Seed)
+	}
+	if cfg.RetryOnDeadline != nil {
+		r = r.RetryOnDeadline(*cfg.RetryOnDeadline)
+	}
+	if cfg.ExactFirstRetry {
+		r = r.ExactFirstRetry()
+	}
+	if cfg.JitterSeed != nil {
+		r = r.JitterSeed(*cfg.JitterSeed)
+	}
+	switch {
+	case cfg.Decorrelated:
+		return r.DecorrelatedBackoff(cfg.Backoff)
+	case cfg.Logarithmic:
+		return r.LogarithmicJitterBackoff(cfg.Backoff, cfg.Jitter)
+	case cfg.Exponential:
 		return r.ExponentialJitterBackoff(cfg.Backoff, cfg.Jitter)
+	default:
+		return r.JitterBackoff(cfg.Backoff, cfg.Jitter)
 	}
-	return r.JitterBackoff(cfg.Backoff, cfg.Jitter)
 }
 
 // Backoff defines the delay after failed Func call.
@@ -86,8 +932,101 @@ type Backoff func(attempt int) time.Duration
 type Retry struct {
 	// attempts is the max number of Func calls.
 	attempts int
-	// backoff defines the delay after failed Func call.
-	backoff Backoff
+	// duration, exponential, logarithmic, decorrelated, jitter and maxBackoff
+	// define the delay after failed Func call, the delay is computed
+	// inline to keep Do free of allocations, see Retry.delay.
+	duration     time.Duration
+	exponential  bool
+	logarithmic  bool
+	decorrelated bool
+	jitter       float64
+	maxBackoff   time.Duration
+	// probability of retrying a failed Func call.
+	probability float64
+	// retryIf classifies errors of functions returning just an error.
+	retryIf Classifier
+	// attemptTimeout limits the duration of each ContextFunc call.
+	attemptTimeout time.Duration
+	// progressiveTimeout doubles attemptTimeout after each attempt.
+	progressiveTimeout bool
+	// deadlineSpread divides the remaining deadline across the remaining attempts.
+	deadlineSpread bool
+	// limiter limits the rate of attempts adapting to errors classified by throttled.
+	limiter   *AdaptiveRateLimiter
+	throttled Classifier
+	// explain is the writer of the trace of decisions.
+	explain io.Writer
+	// recorder observes attempts.
+	recorder Recorder
+	// heartbeat is called every heartbeatInterval of backoff sleeps.
+	heartbeat         func(remaining time.Duration)
+	heartbeatInterval time.Duration
+	// wake interrupts backoff sleeps.
+	wake <-chan struct{}
+	// pacer spaces retries of all Do calls, shared by copies of Retry.
+	pacer *pacer
+	// onExhausted is called when Do gives up.
+	onExhausted func(ctx context.Context, attempts int, lastErr error)
+	// onFailure is called after each retryable failure.
+	onFailure func(err error, resource interface{})
+	// clock is the source of time, the system clock if nil.
+	clock Clock
+	// window limits retries of all Do calls, shared by copies of Retry.
+	window *retryWindow
+	// alignment is the wall clock boundary of retries.
+	alignment time.Duration
+	// hours are the time windows of retries.
+	hours *backoff.Hours
+	// coordinator shares the failures among replicas.
+	coordinator Coordinator
+	// gate allows attempts.
+	gate Gate
+	// locker is held during each Func call.
+	locker sync.Locker
+	// adaptive shrinks attempts during outages.
+	adaptive *AdaptiveAttempts
+	// classOf classifies errors with retries limited by attemptsByClass.
+	classOf         ClassOf
+	attemptsByClass map[Class]int
+	// policies route failures by switchClassOf.
+	policies      map[Class]Retry
+	switchClassOf ClassOf
+	// next are the policies chained by Then.
+	next []Retry
+	// priority scales attempts and backoff by priorityScaler.
+	priority       Priority
+	priorityScaler PriorityScaler
+	// dynamic reloads the policy before each attempt of DynamicRetry.Do.
+	dynamic *DynamicRetry
+	// enabled toggles retries.
+	enabled func(ctx context.Context) bool
+	// onExhaustedError constructs the error of Do when attempts are over.
+	onExhaustedError func(attempts int, lastErr error) error
+	// softDeadline is the duration of Do after which no attempts are started.
+	softDeadline time.Duration
+	// interruptible runs each Func call in its own goroutine,
+	// onOrphaned observes results of calls abandoned by Do.
+	interruptible bool
+	onOrphaned    func(err error)
+	// stopSignals stop Do.
+	stopSignals []os.Signal
+	// deterministic seeds randomized decisions of each Do call by seed.
+	deterministic bool
+	seed          int64
+	// onDeadline decides on errors of exceeded deadlines, if set.
+	onDeadline deadlineDecision
+	// minBackoff floors the delay between Func calls, including jitter.
+	minBackoff time.Duration
+	// exactFirstRetry skips jitter of the delay after the first failure.
+	exactFirstRetry bool
+	// immediateRetries is the number of the first retries without delay.
+	immediateRetries int
+	// jitterDuration is the absolute jitter of backoff, if positive.
+	jitterDuration time.Duration
+	// jitterStream draws jitter of Do calls, if set, shared by copies.
+	jitterStream *jitterStream
+	// multiplier is the growth factor of exponential backoff, 2 if not positive.
+	multiplier float64
 }
 
 // Attempts initializes Retry with the max number of Func calls
@@ -95,6 +1034,18 @@ func Attempts(attempts int) Retry {
 	return Retry{attempts: attempts}
 }
 
+// Forever initializes Retry calling Func until it succeeds,
+// fails permanently, or returns Abort.
+func Forever() Retry {
+	return Attempts(Unlimited)
+}
+
+// None initializes Retry calling Func exactly once,
+// so call sites can use Retry uniformly and disable retries by config.
+func None() Retry {
+	return Attempts(1)
+}
+
 // Backoff defines linear backoff between Func calls.
 func (r Retry) Backoff(duration time.Duration) Retry {
 	return r.JitterBackoff(duration, 0)
@@ -113,7 +1064,7 @@ func (r Retry) ExponentialBackoff(duration time.Duration) Retry {
 // If jitter is out of the range, DefaultJitter is used.
 func (r Retry) JitterBackoff(duration time.Duration, jitter float64) Retry {
 	if duration > 0 {
-		r.backoff = withJitter(linearBackoff(duration), jitter)
+		r.duration, r.exponential, r.logarithmic, r.decorrelated, r.jitter = duration, false, false, false, effectiveJitter(jitter)
 	}
 	return r
 }
@@ -126,124 +1077,1005 @@ func (r Retry) JitterBackoff(duration time.Duration, jitter float64) Retry {
 // 800ms after fourth, 1600ms after fifth.
 func (r Retry) ExponentialJitterBackoff(duration time.Duration, jitter float64) Retry {
 	if duration > 0 {
-		r.backoff = withJitter(exponentialBackoff(duration), jitter)
+		r.duration, r.exponential, r.logarithmic, r.decorrelated, r.jitter = duration, true, false, false, effectiveJitter(jitter)
 	}
 	return r
 }
 
-// Do calls Func until:
-// 1. Func returns (false, ...)
-// 2. Func returns (true, ...) but attempts exceeded
-// 3. context cancellation signal received
-func (r Retry) Do(ctx context.Context, call Func) error {
-	if r.backoff == nil || r.attempts < 2 {
-		return r.do(ctx, call)
-	}
-	return r.doWithBackoff(ctx, call)
+// Multiplier replaces 2 as the growth factor of exponential backoff,
+// e.g. with multiplier 1.5 and duration 100ms backoff equals 100ms after
+// first attempt, 150ms after second, 225ms after third.
+// Non-positive multiplier restores doubling.
+func (r Retry) Multiplier(multiplier float64) Retry {
+	r.multiplier = multiplier
+	return r
+}
+
+// LogarithmicBackoff defines logarithmic backoff between Func calls.
+// The backoff is multiplied by the binary logarithm of the current attempt plus 2,
+// so it grows but flattens quickly. For example, if duration is 100ms,
+// then backoff equals 100ms after first attempt, 200ms after third, 300ms after seventh.
+func (r Retry) LogarithmicBackoff(duration time.Duration) Retry {
+	return r.LogarithmicJitterBackoff(duration, 0)
+}
+
+// LogarithmicJitterBackoff defines logarithmic backoff with jitter between Func calls.
+// Duration expected to be positive, jitter expected to be in range [0.0, 1.0).
+// If jitter is out of the range, DefaultJitter is used.
+// See LogarithmicBackoff for the growth of the backoff.
+func (r Retry) LogarithmicJitterBackoff(duration time.Duration, jitter float64) Retry {
+	if duration > 0 {
+		r.duration, r.exponential, r.logarithmic, r.decorrelated, r.jitter = duration, false, true, false, effectiveJitter(jitter)
+	}
+	return r
+}
+
+// DecorrelatedBackoff defines decorrelated jitter backoff between Func calls:
+// the delay is random between duration and 3 times the previous delay,
+// usually capped by MaxBackoff. The backoff is stateful, the state is isolated
+// per Do call, see Retry.NewSession for the state shared across Do calls.
+func (r Retry) DecorrelatedBackoff(duration time.Duration) Retry {
+	if duration > 0 {
+		r.duration, r.exponential, r.logarithmic, r.decorrelated, r.jitter = duration, false, false, true, 0
+	}
+	return r
+}
+
+// MaxBackoff caps the delay between Func calls, including jitter.
+// Non-positive duration removes the cap.
+func (r Retry) MaxBackoff(duration time.Duration) Retry {
+	r.maxBackoff = duration
+	return r
+}
+
+// MinBackoff floors the delay between Func calls, including jitter,
+// it takes precedence over MaxBackoff. Non-positive duration removes the floor.
+func (r Retry) MinBackoff(duration time.Duration) Retry {
+	r.minBackoff = duration
+	return r
+}
+
+// RetryProbability retries failed Func calls with probability p,
+// expected to be in range (0.0, 1.0). If p is out of the range,
+// every failure is retried.
+func (r Retry) RetryProbability(p float64) Retry {
+	r.probability = p
+	return r
+}
+
+// RetryIf sets Classifier of errors of functions returning just an error,
+// e.g. DoTyped. Every error is retried if classifier is nil.
+func (r Retry) RetryIf(classifier Classifier) Retry {
+	r.retryIf = classifier
+	return r
+}
+
+// AttemptTimeout limits the duration of each ContextFunc call,
+// the context of the call is cancelled when timeout is exceeded.
+func (r Retry) AttemptTimeout(timeout time.Duration) Retry {
+	r.attemptTimeout = timeout
+	return r
+}
+
+// ProgressiveTimeout doubles the attempt timeout after each attempt,
+// e.g. 1s, 2s, 4s for AttemptTimeout of 1s.
+func (r Retry) ProgressiveTimeout() Retry {
+	r.progressiveTimeout = true
+	return r
+}
+
+// DeadlineSpread limits the duration of each ContextFunc call with
+// the remaining deadline of the context divided by the remaining attempts.
+// The limit is combined with AttemptTimeout, the lower one wins.
+func (r Retry) DeadlineSpread() Retry {
+	r.deadlineSpread = true
+	return r
+}
+
+// RateLimiter limits the rate of attempts by limiter, adapting it
+// to errors classified by throttled, OnThrottling is used if it is nil.
+func (r Retry) RateLimiter(limiter *AdaptiveRateLimiter, throttled Classifier) Retry {
+	if limiter != nil && throttled == nil {
+		throttled = OnThrottling()
+	}
+	r.limiter, r.throttled = limiter, throttled
+	return r
+}
+
+// Explain writes the trace of decisions made by Do to w, one line per decision.
+// Writes of concurrent Do calls are not synchronized.
+func (r Retry) Explain(w io.Writer) Retry {
+	r.explain = w
+	return r
+}
+
+// Recorder attaches recorders observing attempts, replacing attached ones.
+func (r Retry) Recorder(recorders ...Recorder) Retry {
+	r.recorder = Recorders(recorders...)
+	return r
+}
+
+// Heartbeat calls heartbeat every interval of backoff sleeps
+// with the remaining time of the sleep.
+func (r Retry) Heartbeat(interval time.Duration, heartbeat func(remaining time.Duration)) Retry {
+	r.heartbeatInterval, r.heartbeat = interval, heartbeat
+	return r
+}
+
+// Wake interrupts the current backoff sleep by a signal of wake,
+// so Func is called immediately.
+func (r Retry) Wake(wake <-chan struct{}) Retry {
+	r.wake = wake
+	return r
+}
+
+// Pacing spaces retries of all Do calls of Retry and its copies evenly,
+// so there are at most retriesPerSecond retries per second regardless of
+// individual backoff schedules. Non-positive value removes the limit.
+func (r Retry) Pacing(retriesPerSecond float64) Retry {
+	r.pacer = newPacer(retriesPerSecond)
+	return r
+}
+
+// OnExhausted calls onExhausted when Do gives up retrying a temporary error
+// after attempts: no attempts left or retry is declined.
+func (r Retry) OnExhausted(onExhausted func(ctx context.Context, attempts int, lastErr error)) Retry {
+	r.onExhausted = onExhausted
+	return r
+}
+
+// OnRetryableFailure calls onFailure after each attempt failed with
+// a temporary error, with the resource attached to the error by FailedOn.
+func (r Retry) OnRetryableFailure(onFailure func(err error, resource interface{})) Retry {
+	r.onFailure = onFailure
+	return r
+}
+
+// Clock sets the source of time of attempt durations, deadlines
+// and backoff sleeps, SystemClock if nil.
+func (r Retry) Clock(clock Clock) Retry {
+	r.clock = clock
+	return r
+}
+
+// MaxRetriesPer limits retries of all Do calls of Retry and its copies
+// to n per sliding window: a retry exceeding the limit is delayed.
+// Non-positive window or n removes the limit.
+func (r Retry) MaxRetriesPer(window time.Duration, n int) Retry {
+	r.window = newRetryWindow(window, n)
+	return r
+}
+
+// Aligned extends backoff delays, so retries happen at wall clock
+// boundaries, multiples of boundary, e.g. every 15 seconds at :00, :15, :30
+// and :45. Non-positive boundary removes the alignment.
+func (r Retry) Aligned(boundary time.Duration) Retry {
+	r.alignment = boundary
+	return r
+}
+
+// Within delays retries due outside of hours until the window opens.
+// Nil hours remove the limit.
+func (r Retry) Within(hours *backoff.Hours) Retry {
+	r.hours = hours
+	return r
+}
+
+// Coordinator shares the consecutive failures among replicas by coordinator,
+// so the backoff of all replicas escalates together. Nil coordinator
+// keeps the backoff local.
+func (r Retry) Coordinator(coordinator Coordinator) Retry {
+	r.coordinator = coordinator
+	return r
+}
+
+// Gate checks gate before each attempt, Do returns ErrGateClosed
+// when it is denied. Nil gate allows every attempt.
+func (r Retry) Gate(gate Gate) Retry {
+	r.gate = gate
+	return r
+}
+
+// Locked returns a copy of policy holding mu for the duration of each
+// Func call only, mu is unlocked during backoff sleeps, so other goroutines
+// are not blocked by a sleeping retry loop. Nil mu removes the locking.
+func Locked(mu sync.Locker, policy Retry) Retry {
+	policy.locker = mu
+	return policy
+}
+
+// AdaptiveAttempts shrinks the attempts of Do calls while the recent
+// failure rate tracked by adaptive is high. Nil adaptive keeps the attempts.
+func (r Retry) AdaptiveAttempts(adaptive *AdaptiveAttempts) Retry {
+	r.adaptive = adaptive
+	return r
+}
+
+// AttemptsByClass limits retries of errors per class returned by classOf,
+// errors of classes out of retries are retried up to the attempts of Retry.
+// Nil classOf removes the limits.
+func (r Retry) AttemptsByClass(classOf ClassOf, retries map[Class]int) Retry {
+	r.classOf, r.attemptsByClass = classOf, retries
+	return r
 }
 
-func (r Retry) do(ctx context.Context, call Func) error {
+// Priority scales the attempts and the backoff for work of priority p
+// by scaler, DefaultPriorityScaler if nil.
+func (r Retry) Priority(p Priority, scaler PriorityScaler) Retry {
+	r.priority, r.priorityScaler = p, scaler
+	return r
+}
+
+// Enabled evaluates enabled before each retry, Do returns the error
+// of the failed Func call when it reports false. Nil enabled allows retries.
+func (r Retry) Enabled(enabled func(ctx context.Context) bool) Retry {
+	r.enabled = enabled
+	return r
+}
+
+// ExhaustedError sets the constructor of the error returned by Do when
+// attempts are over, nil restores "no attempts left: <last error>".
+func (r Retry) ExhaustedError(exhaustedError func(attempts int, lastErr error) error) Retry {
+	r.onExhaustedError = exhaustedError
+	return r
+}
+
+// SoftDeadline stops starting new attempts after d since the start of Do,
+// the in-flight attempt is not interrupted. Zero d disables the deadline.
+func (r Retry) SoftDeadline(d time.Duration) Retry {
+	r.softDeadline = d
+	return r
+}
+
+// Interruptible runs each Func call in its own goroutine, so Do returns
+// as soon as the context passed to it is done, even if Func ignores
+// cancellation; attempt timeouts don't interrupt Func. A panic of Func
+// is raised again by the goroutine of Do. onOrphaned, if not nil,
+// receives the error of the abandoned call, see Config.Interruptible.
+func (r Retry) Interruptible(onOrphaned func(err error)) Retry {
+	r.interruptible, r.onOrphaned = true, onOrphaned
+	return r
+}
+
+// StopSignals stops Do on the first of signals: the current attempt
+// is finished, but no attempt is started after it, Do returns ErrStopped.
+func (r Retry) StopSignals(signals ...os.Signal) Retry {
+	r.stopSignals = signals
+	return r
+}
+
+// Deterministic makes jitter, decorrelated backoff and retry probability
+// of every Do call reproducible from seed, e.g. to replay a failure.
+func (r Retry) Deterministic(seed int64) Retry {
+	r.deterministic, r.seed = true, seed
+	return r
+}
+
+// RetryOnDeadline decides on ContextFunc errors matching
+// context.DeadlineExceeded instead of their classifier: retry true retries
+// attempts exceeding their own timeout, but not the deadline of the context
+// passed to Do, retry false retries neither.
+func (r Retry) RetryOnDeadline(retry bool) Retry {
+	r.onDeadline = skipDeadline
+	if retry {
+		r.onDeadline = retryDeadline
+	}
+	return r
+}
+
+// ExactFirstRetry makes the first retry after the backoff without jitter,
+// jitter and decorrelated backoff apply from the second retry on.
+func (r Retry) ExactFirstRetry() Retry {
+	r.exactFirstRetry = true
+	return r
+}
+
+// ImmediateRetries makes the first n retries without delay,
+// the backoff starts after them from its initial delay.
+// Non-positive n removes immediate retries.
+func (r Retry) ImmediateRetries(n int) Retry {
+	if n < 0 {
+		n = 0
+	}
+	r.immediateRetries = n
+	return r
+}
+
+// JitterDuration applies jitter of an absolute duration to backoff,
+// the delay is random within ±jitter around the backoff, e.g. ±50ms
+// around 10ms backoff. It takes precedence over the jitter fraction of
+// the backoff, decorrelated backoff is not affected. Non-positive jitter
+// removes it.
+func (r Retry) JitterDuration(jitter time.Duration) Retry {
+	r.jitterDuration = jitter
+	return r
+}
+
+// JitterSeed makes the sequence of jitter and decorrelated backoff
+// reproducible from seed across runs, Do calls and copies of Retry continue
+// the same sequence. Policies derived by With restart it. Deterministic
+// takes precedence.
+func (r Retry) JitterSeed(seed int64) Retry {
+	r.jitterStream = &jitterStream{seed: seed, state: uint64(seed)}
+	return r
+}
+
+// With returns a copy of Retry adjusted by opts,
+// e.g. the same backoff with more attempts.
+func (r Retry) With(opts ...Option) Retry {
+	return New(r.config().With(opts...))
+}
+
+// Config returns Config of Retry, so the policy can be merged with
+// another one by Config.Merge and validated by Config.Validate.
+func (r Retry) Config() Config {
+	return r.config()
+}
+
+// config returns Config of Retry, see New
+func (r Retry) config() Config {
+	window, maxRetries := r.window.limit()
+	return Config{
+		Attempts:           r.attempts,
+		Backoff:            r.duration,
+		Exponential:        r.exponential,
+		Jitter:             r.jitter,
+		Decorrelated:       r.decorrelated,
+		Logarithmic:        r.logarithmic,
+		MaxBackoff:         r.maxBackoff,
+		RetryProbability:   r.probability,
+		RetryIf:            r.retryIf,
+		AttemptTimeout:     r.attemptTimeout,
+		ProgressiveTimeout: r.progressiveTimeout,
+		DeadlineSpread:     r.deadlineSpread,
+		RateLimiter:        r.limiter,
+		Throttled:          r.throttled,
+		Explain:            r.explain,
+		Recorder:           r.recorder,
+		Heartbeat:          r.heartbeat,
+		HeartbeatInterval:  r.heartbeatInterval,
+		Wake:               r.wake,
+		Pacing:             r.pacer.rate(),
+		OnExhausted:        r.onExhausted,
+		OnRetryableFailure: r.onFailure,
+		Clock:              r.clock,
+		MaxRetries:         maxRetries,
+		MaxRetriesWindow:   window,
+		Alignment:          r.alignment,
+		Hours:              r.hours,
+		Coordinator:        r.coordinator,
+		Gate:               r.gate,
+		Locker:             r.locker,
+		AdaptiveAttempts:   r.adaptive,
+		AttemptsByClass:    r.attemptsByClass,
+		ClassOf:            r.classOf,
+		Policies:           r.policies,
+		SwitchClassOf:      r.switchClassOf,
+		Then:               r.next,
+		Priority:           r.priority,
+		PriorityScaler:     r.priorityScaler,
+		Enabled:            r.enabled,
+		ExhaustedError:     r.onExhaustedError,
+		SoftDeadline:       r.softDeadline,
+		Interruptible:      r.interruptible,
+		OnOrphaned:         r.onOrphaned,
+		StopSignals:        r.stopSignals,
+		Deterministic:      r.deterministic,
+		Seed:               r.seed,
+		RetryOnDeadline:    r.onDeadline.config(),
+		MinBackoff:         r.minBackoff,
+		ExactFirstRetry:    r.exactFirstRetry,
+		ImmediateRetries:   r.immediateRetries,
+		JitterDuration:     r.jitterDuration,
+		JitterSeed:         r.jitterStream.config(),
+		Multiplier:         r.multiplier,
+	}
+}
+
+// Do calls Func until:
+// 1. Func returns (false, ...) or Abort error
+// 2. Func returns (true, ...) but attempts exceeded
+// 3. context cancellation signal received
+// 4. retry is declined by RetryProbability or the global budget
+// Func is called once, if retries are disabled by DisableRetries.
+// Overrides of ctx set by WithContextOverrides are applied to the policy.
+func (r Retry) Do(ctx context.Context, call Func) error {
+	var state backoffState
+	return r.do(ctx, call, &state, nil, nil)
+}
+
+// do calls Func continuing the backoff state, describes the call by stats
+// if not nil. The returned error carries the attempts, the time since
+// the first failure and the time slept. The clock is read only when stats
+// are requested or an attempt fails, not on the hot path of successful calls.
+// The scope of each attempt is set to scope, if not nil, see DoContext.
+func (r *Retry) do(ctx context.Context, call Func, state *backoffState, stats *Stats, scope *attemptScope) error {
 	var (
-		err   error
-		retry bool
+		local   Stats
+		started time.Time
 	)
+	requested := stats != nil
+	if requested {
+		started = r.now()
+	} else {
+		stats = &local
+	}
+
+	err := r.run(ctx, call, state, stats, scope)
+	if requested {
+		stats.Elapsed = r.since(started)
+	}
+	if err == nil {
+		return nil
+	}
+
+	var retrying time.Duration
+	if !stats.failed.IsZero() {
+		retrying = r.since(stats.failed)
+	}
+	return &metadataError{err: err, attempts: stats.Attempts, elapsed: retrying, slept: stats.Slept}
+}
+
+// run calls Func until it succeeds or Do gives up, counting attempts by stats
+func (r *Retry) run(ctx context.Context, call Func, state *backoffState, stats *Stats, scope *attemptScope) error {
+	r.override(ctx)
+	lim := r.limits(ctx)
+
+	var (
+		err     error
+		retry   bool
+		classes classRetries
+		routes  routing
+		chain   stages
+		loaded  *Retry
+		began   time.Time
+	)
+	if r.softDeadline > 0 {
+		began = r.now()
+	}
+
+	// the attempts are called with the context passed to Do,
+	// so a stop signal doesn't interrupt the current attempt
+	parent := ctx
+	ctx, stop := r.notifyStop(ctx)
+	defer stop()
+	for attempt := 0; attempt < lim.bound; attempt++ {
+		if r.dynamic != nil && r.reload(ctx, &loaded) {
+			lim = r.limits(ctx)
+			if attempt >= lim.bound {
+				break
+			}
+		}
 
-	for attempt := 0; attempt < r.attempts; attempt++ {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			r.explainf("context done before attempt %d: %v", attempt+1, ctx.Err())
+			return r.stopped(parent, ctx.Err())
 		default:
-			if retry, err = call(); !retry {
+		}
+
+		if r.gate != nil && !r.gate.Allow(ctx) {
+			r.explainf("gate closed before attempt %d", attempt+1)
+			return ErrGateClosed
+		}
+
+		if r.limiter != nil {
+			if err := r.limiter.Acquire(ctx); err != nil {
 				return err
 			}
 		}
+
+		var started time.Time
+		if r.recorder != nil || stats.history {
+			started = r.now()
+		}
+		if r.recorder != nil {
+			r.recorder.AttemptStarted(ctx, attempt)
+		}
+
+		if scope != nil {
+			scope.policy, scope.attempt, scope.left = *r, attempt, lim.attempts-attempt
+			switch {
+			case lim.routed:
+				scope.left = lim.attempts - routes.fallback
+			case lim.chained:
+				scope.left = r.stageLeft(&chain, attempt)
+			}
+		}
+
+		retry, err = r.attempt(parent, call)
+		stats.Attempts++
+		if err != nil && stats.failed.IsZero() {
+			stats.failed = r.now()
+		}
+		if stats.history {
+			stats.History = append(stats.History, AttemptStats{Start: started, End: r.now(), Err: err})
+		}
+
+		if r.recorder != nil {
+			r.recorder.AttemptEnded(ctx, attempt, err, r.since(started))
+		}
+
+		if r.adaptive != nil {
+			r.adaptive.record(retry && err != nil)
+		}
+
+		if r.limiter != nil {
+			r.limiter.Update(err != nil && r.throttled(err))
+		}
+
+		if err == nil {
+			r.coordinateSuccess(ctx)
+		}
+
+		if !retry || aborted(err) {
+			r.explainResult(attempt, err)
+			return unwrapAbort(err)
+		}
+
+		if r.onFailure != nil {
+			r.onFailure(err, failedResource(err))
+		}
+
+		r.coordinateFailure(ctx, state)
+
+		policy, policyState, exhausted := *r, state, attempt == lim.attempts-1
+		switch {
+		case lim.routed:
+			var failures int
+			policy, policyState, failures = r.route(&routes, err, state)
+			exhausted = failures >= policy.attempts
+		case lim.chained:
+			policy, policyState, exhausted = r.stage(&chain, attempt, state)
+		}
+
+		// skip backoff after last attempt
+		if exhausted {
+			r.explainf("attempt %d failed: %v; retryable, but no attempts left", attempt+1, err)
+			r.exhausted(ctx, attempt+1, err)
+			return r.exhaustedError(attempt+1, err)
+		}
+
+		if r.enabled != nil && !r.enabled(ctx) {
+			r.explainf("attempt %d failed: %v; retryable, but retries are disabled", attempt+1, err)
+			r.exhausted(ctx, attempt+1, err)
+			return err
+		}
+
+		if !r.allowClass(&classes, err) {
+			r.exhausted(ctx, attempt+1, err)
+			return r.exhaustedError(attempt+1, err)
+		}
+
+		if ok, denied := r.allowRetry(state, err); !ok {
+			r.exhausted(ctx, attempt+1, err)
+			return denied
+		}
+
+		delay, base := policy.delayAfter(policyState, err)
+		delay, base = r.scaledDelay(delay, err), r.scaledDelay(base, err)
+		policy.explainDelay(attempt, err, delay, base)
+		if r.alignment > 0 {
+			delay = backoff.Aligned(r.now(), delay, r.alignment)
+		}
+		if r.hours != nil {
+			delay = r.hours.Until(r.now(), delay)
+		}
+		if r.window != nil {
+			delay = r.window.until(r.now(), delay)
+		}
+		if r.pacer != nil {
+			delay = r.pacer.until(r.now(), delay)
+		}
+		if r.softDeadline > 0 && r.since(began)+delay > r.softDeadline {
+			r.explainf("attempt %d failed: %v; retryable, but soft deadline %s would pass", attempt+1, err, r.softDeadline)
+			r.exhausted(ctx, attempt+1, err)
+			return r.exhaustedError(attempt+1, err)
+		}
+		// the slots are reserved only for the retry decided above
+		if r.window != nil {
+			delay = r.window.reserve(r.now(), delay)
+		}
+		if r.pacer != nil {
+			delay = r.pacer.reserve(r.now(), delay)
+		}
+		if delay > 0 {
+			sleeping := r.now()
+			err := r.sleep(ctx, delay)
+			slept := r.since(sleeping)
+			stats.Slept += slept
+			if delays, ok := r.recorder.(DelayRecorder); ok {
+				delays.Slept(ctx, attempt, slept)
+			}
+			if err != nil {
+				r.explainf("context done while sleeping: %v", err)
+				return r.stopped(parent, err)
+			}
+		}
 	}
 
+	r.exhausted(ctx, lim.attempts, err)
+	return r.exhaustedError(lim.attempts, err)
+}
+
+// exhaustedError returns the error of Do giving up after attempts
+func (r *Retry) exhaustedError(attempts int, err error) error {
+	if r.onExhaustedError != nil {
+		return r.onExhaustedError(attempts, err)
+	}
 	return noAttemptsLeft{reason: err}
 }
 
-func (r Retry) doWithBackoff(ctx context.Context, call Func) error {
-	// lazy initialization and destruction of timer, as usually
-	// Func returns a successful result at the first call
-	var timer *time.Timer
-	defer func() {
-		if timer != nil {
-			timer.Stop()
-		}
-	}()
+// limits are the limits of attempts of a Do call
+type limits struct {
+	// attempts is the max number of Func calls of Retry itself
+	attempts int
+	// bound is the max number of Func calls including routed and chained ones
+	bound int
+	// routed and chained failures are limited by the attempts of their policies
+	routed, chained bool
+}
 
-	var (
-		err   error
+// limits returns the limits of attempts of a Do call with ctx
+func (r *Retry) limits(ctx context.Context) limits {
+	full := r.scaledAttempts(r.attempts)
+	lim := limits{attempts: full}
+	if lim.attempts > 1 && retriesDisabled(ctx) {
+		lim.attempts = 1
+	}
+	if r.adaptive != nil {
+		lim.attempts = r.adaptive.attempts(lim.attempts)
+	}
+
+	// routes and chains are skipped when the attempts are reduced
+	lim.routed = r.policies != nil && r.switchClassOf != nil && lim.attempts == full
+	lim.chained = r.next != nil && lim.attempts == full
+	lim.bound = lim.attempts
+	if lim.routed || lim.chained {
+		lim.bound = Unlimited
+	}
+	return lim
+}
+
+// attempt calls Func, in its own goroutine if Retry is interruptible
+func (r *Retry) attempt(ctx context.Context, call Func) (bool, error) {
+	if !r.interruptible {
+		return lockedCall(r.locker, call)
+	}
+
+	return interruptibleCall(ctx, r.locker, call, r.onOrphaned)
+}
+
+// interruptibleCall calls Func in its own goroutine and returns the error
+// of ctx when it is done first, fields of Retry are passed separately,
+// so Retry doesn't escape to the heap on the hot path of Do
+func interruptibleCall(ctx context.Context, locker sync.Locker, call Func, onOrphaned func(err error)) (bool, error) {
+	type result struct {
 		retry bool
-		last  = r.attempts - 1
-	)
-	for attempt := 0; attempt < r.attempts; attempt++ {
-		if retry, err = call(); !retry {
-			return err
-		}
+		err   error
+		// panicked is set with the recovered value when Func panics,
+		// the panic is raised again by the goroutine of Do
+		panicked bool
+		value    interface{}
+	}
+	done := make(chan result, 1)
+	go func() {
+		res := result{panicked: true}
+		defer func() {
+			if res.panicked {
+				res.value = recover()
+			}
+			done <- res
+		}()
+		res.retry, res.err = lockedCall(locker, call)
+		res.panicked = false
+	}()
 
-		// skip backoff after last attempt
-		if attempt == last {
-			break
+	select {
+	case res := <-done:
+		if res.panicked {
+			panic(res.value)
 		}
+		return res.retry, res.err
+	case <-ctx.Done():
+		if onOrphaned != nil {
+			go func() {
+				res := <-done
+				if res.panicked {
+					res.err = fmt.Errorf("retry: orphaned call panicked: %v", res.value)
+				}
+				onOrphaned(res.err)
+			}()
+		}
+		return false, ctx.Err()
+	}
+}
 
-		duration := r.backoff(attempt)
-		if timer == nil {
-			timer = time.NewTimer(duration)
-		} else {
-			timer.Reset(duration)
+// lockedCall calls Func holding locker, if any
+func lockedCall(locker sync.Locker, call Func) (bool, error) {
+	if locker == nil {
+		return call()
+	}
+	locker.Lock()
+	defer locker.Unlock()
+	return call()
+}
+
+// exhausted notifies about giving up after attempts
+func (r *Retry) exhausted(ctx context.Context, attempts int, err error) {
+	if r.recorder != nil {
+		r.recorder.Exhausted(ctx, attempts, err)
+	}
+	if r.onExhausted != nil {
+		r.onExhausted(ctx, attempts, err)
+	}
+}
+
+// sleep waits for the backoff duration, calling heartbeat if set.
+// The sleep is interrupted by the wake channel, if set.
+func (r Retry) sleep(ctx context.Context, duration time.Duration) error {
+	if r.heartbeat == nil || r.heartbeatInterval <= 0 {
+		_, err := r.wait(ctx, duration)
+		return err
+	}
+
+	for remaining := duration; remaining > 0; remaining -= r.heartbeatInterval {
+		if remaining <= r.heartbeatInterval {
+			_, err := r.wait(ctx, remaining)
+			return err
+		}
+		if woken, err := r.wait(ctx, r.heartbeatInterval); woken || err != nil {
+			return err
 		}
+		r.heartbeat(remaining - r.heartbeatInterval)
+	}
+	return nil
+}
 
+// Sleep waits for duration or context cancellation signal,
+// whichever comes first, and returns the error of the context in the latter case.
+// Non-positive duration returns immediately. Timers are reused across calls:
+// a timer is stopped and its channel drained before it is put back,
+// so it never delivers a stale value after Reset.
+func Sleep(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+	_, err := wait(ctx, duration, nil)
+	return err
+}
+
+// wait works same as Sleep, but returns woken true
+// when interrupted by a signal of wake channel
+func wait(ctx context.Context, duration time.Duration, wake <-chan struct{}) (woken bool, err error) {
+	// timers are pooled, as allocation of timer per Func call failure
+	// is noticeable for high-QPS callers
+	timer := acquireTimer(duration)
+	defer releaseTimer(timer)
+
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-wake:
+		return true, nil
+	case <-timer.C:
+		return false, nil
+	}
+}
+
+// wait works same as package wait, but waits by the clock of Retry
+// and is interrupted by its wake channel
+func (r Retry) wait(ctx context.Context, duration time.Duration) (woken bool, err error) {
+	if r.clock == nil {
+		return wait(ctx, duration, r.wake)
+	}
+
+	timer := r.clock.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-r.wake:
+		return true, nil
+	case <-timer.C():
+		return false, nil
+	}
+}
+
+// timers is a pool of stopped and drained timers
+var timers sync.Pool
+
+func acquireTimer(duration time.Duration) *time.Timer {
+	if timer, ok := timers.Get().(*time.Timer); ok {
+		timer.Reset(duration)
+		return timer
+	}
+	return time.NewTimer(duration)
+}
+
+func releaseTimer(timer *time.Timer) {
+	// drain the channel, if the timer fired but was not received,
+	// so the next Reset does not observe a stale value
+	if !timer.Stop() {
 		select {
-		case <-ctx.Done():
-			return ctx.Err()
 		case <-timer.C:
+		default:
 		}
 	}
+	timers.Put(timer)
+}
 
-	return noAttemptsLeft{reason: err}
+// thinned reports whether only a fraction of failures is retried
+func (r Retry) thinned() bool {
+	return r.probability > 0 && r.probability < 1
 }
 
-// withJitter wraps Backoff with jitter
-func withJitter(backoff Backoff, jitter float64) Backoff {
-	if jitter < 0 || jitter >= 1 {
-		jitter = DefaultJitter
+// allowRetry reports whether Func may be called again after err,
+// otherwise returns the error Do should return.
+func (r Retry) allowRetry(state *backoffState, err error) (bool, error) {
+	if r.thinned() && r.lockedRandom(state) >= r.probability {
+		r.explainf("retry declined by retry probability %.4g%%", r.probability*100)
+		return false, err
+	}
+	if !allowGlobalRetry() {
+		r.explainf("retry declined by global budget")
+		return false, budgetExceeded{reason: err}
 	}
+	return true, nil
+}
+
+// delayAfter returns the backoff after attempt failed with err and
+// advances the state, the delay requested by RetryAfter takes precedence.
+// Base is the delay before jitter.
+func (r Retry) delayAfter(state *backoffState, err error) (delay, base time.Duration) {
+	state.lock()
+	defer state.unlock()
+
+	failures := state.failures
+	state.failures++
 
-	if jitter == 0 {
-		return backoff
+	if delay, ok := requestedDelay(err); ok {
+		return delay, delay
+	}
+	if failures < r.immediateRetries {
+		return 0, 0
+	}
+	failures -= r.immediateRetries
+	if r.duration <= 0 {
+		return 0, 0
+	}
+	if r.decorrelated {
+		if r.exactFirstRetry && failures == 0 {
+			state.prev = r.capped(r.duration)
+		} else {
+			state.prev = r.decorrelatedDelay(state.prev, r.jitterRandom(state))
+		}
+		return state.prev, state.prev
 	}
+	return r.delay(state, failures)
+}
 
-	return func(attempt int) time.Duration {
-		duration := backoff(attempt)
-		return jitterUp(duration, jitter)
+// delay returns the backoff after failed attempt and the backoff before jitter,
+// state must be locked
+func (r Retry) delay(state *backoffState, attempt int) (delay, base time.Duration) {
+	base = r.baseDelay(attempt)
+	delay = base
+	switch {
+	case r.exactFirstRetry && attempt == 0:
+	case r.jitterDuration > 0:
+		delay = backoff.JitteredBy(base, r.jitterDuration, r.jitterRandom(state))
+	case r.jitter > 0:
+		delay = backoff.Jittered(base, r.jitter, r.jitterRandom(state))
 	}
+	return r.capped(delay), r.capped(base)
 }
 
-// jitterUp applies jitter for duration
-func jitterUp(duration time.Duration, jitter float64) time.Duration {
-	seedOnce.Do(func() {
-		randomizer = rand.New(rand.NewSource(time.Now().UnixNano()))
-	})
-	// multiplier is in the range (1-jitter, 1+jitter)
-	multiplier := 1 + jitter*(randomizer.Float64()*2-1)
-	return time.Duration(float64(duration) * multiplier)
+// decorrelatedDelay returns the backoff following prev one:
+// random between duration and 3 times prev, rnd is in range [0.0, 1.0)
+func (r Retry) decorrelatedDelay(prev time.Duration, rnd float64) time.Duration {
+	return r.capped(backoff.Decorrelated(r.duration, prev, rnd))
 }
 
-var (
-	// randomizer generates jitter value
-	randomizer *rand.Rand
-	// seedOnce initializes randomizer
-	seedOnce sync.Once
+// capped applies MaxBackoff and MinBackoff to duration
+func (r Retry) capped(duration time.Duration) time.Duration {
+	duration = backoff.Capped(duration, r.maxBackoff)
+	if duration < r.minBackoff {
+		return r.minBackoff
+	}
+	return duration
+}
 
-	linearBackoff = func(duration time.Duration) Backoff {
-		return func(_ int) time.Duration { return duration }
+// baseDelay returns the backoff after failed attempt before jitter
+func (r Retry) baseDelay(attempt int) time.Duration {
+	switch {
+	case r.exponential && r.multiplier > 0:
+		return backoff.ExpBy(r.duration, r.multiplier, attempt)
+	case r.exponential:
+		// saturates instead of overflow, e.g. for Forever policies
+		return backoff.Exp(r.duration, attempt)
+	case r.logarithmic:
+		return backoff.Log(r.duration, attempt)
 	}
+	return r.duration
+}
 
-	exponentialBackoff = func(duration time.Duration) Backoff {
-		return func(attempt int) time.Duration { return duration << attempt }
+// effectiveJitter replaces jitter out of range [0.0, 1.0) with DefaultJitter
+func effectiveJitter(jitter float64) float64 {
+	if jitter < 0 || jitter >= 1 {
+		return DefaultJitter
 	}
-)
+	return jitter
+}
+
+// randomFloat returns a pseudo-random number in range [0.0, 1.0).
+// It is lock-free: splitmix64 over an atomic counter,
+// good enough for jitter and thread-safe.
+func randomFloat() float64 {
+	return splitmix(atomic.AddUint64(&randomState, 0x9e3779b97f4a7c15))
+}
+
+// splitmix returns a pseudo-random number in range [0.0, 1.0) for state x
+func splitmix(x uint64) float64 {
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	x ^= x >> 31
+	return float64(x>>11) / (1 << 53)
+}
+
+// random returns a pseudo-random number in range [0.0, 1.0) reproducible
+// from the seed of Retry, if set, state must be locked
+func (r Retry) random(state *backoffState) float64 {
+	if !r.deterministic {
+		return randomFloat()
+	}
+	if !state.seeded {
+		state.random, state.seeded = uint64(r.seed), true
+	}
+	state.random += 0x9e3779b97f4a7c15
+	return splitmix(state.random)
+}
+
+// jitterRandom works same as random, but draws from the jitter stream
+// of Retry, if set and Retry is not deterministic, state must be locked
+func (r Retry) jitterRandom(state *backoffState) float64 {
+	if r.jitterStream != nil && !r.deterministic {
+		return r.jitterStream.next()
+	}
+	return r.random(state)
+}
+
+// jitterStream is a seeded sequence of pseudo-random numbers,
+// it is lock-free, see randomFloat
+type jitterStream struct {
+	// state is first to be 64-bit aligned for atomic operations
+	state uint64
+	seed  int64
+}
+
+// next returns the next number of the stream in range [0.0, 1.0)
+func (s *jitterStream) next() float64 {
+	return splitmix(atomic.AddUint64(&s.state, 0x9e3779b97f4a7c15))
+}
+
+// config returns the seed of the stream for Config.JitterSeed
+func (s *jitterStream) config() *int64 {
+	if s == nil {
+		return nil
+	}
+	seed := s.seed
+	return &seed
+}
+
+// lockedRandom works same as random, but locks state
+func (r Retry) lockedRandom(state *backoffState) float64 {
+	state.lock()
+	defer state.unlock()
+	return r.random(state)
+}
+
+// randomState is the state of randomFloat
+var randomState = uint64(time.Now().UnixNano())
 
 type noAttemptsLeft struct {
 	reason error
@@ -0,0 +1,61 @@
+// Package retrybench measures the overhead of retry policies,
+// so configurations can be compared objectively, e.g.
+//
+//	fmt.Println(retrybench.Measure(policy))
+//	// 18 ns/op, 0 B/op, 0 allocs/op
+package retrybench
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/osvim/retry"
+)
+
+// Result is the overhead of Do of a policy per call.
+type Result struct {
+	NsPerOp     int64
+	AllocsPerOp int64
+	BytesPerOp  int64
+}
+
+// String formats Result as benchmarks do, e.g. "18 ns/op, 0 B/op, 0 allocs/op".
+func (r Result) String() string {
+	return fmt.Sprintf("%d ns/op, %d B/op, %d allocs/op", r.NsPerOp, r.BytesPerOp, r.AllocsPerOp)
+}
+
+// Duration returns the time per call.
+func (r Result) Duration() time.Duration {
+	return time.Duration(r.NsPerOp)
+}
+
+// noop is retry.Func succeeding immediately
+func noop() (bool, error) { return false, nil }
+
+// Benchmark runs Do of the policy against a no-op function b.N times,
+// it is called by benchmarks of adopters:
+//
+//	func BenchmarkPolicy(b *testing.B) { retrybench.Benchmark(b, policy) }
+func Benchmark(b *testing.B, policy retry.Retry) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = policy.Do(ctx, noop)
+	}
+}
+
+// Measure runs Benchmark of the policy outside of go test,
+// e.g. in a command comparing configurations. It takes about a second.
+func Measure(policy retry.Retry) Result {
+	res := testing.Benchmark(func(b *testing.B) {
+		Benchmark(b, policy)
+	})
+	return Result{
+		NsPerOp:     res.NsPerOp(),
+		AllocsPerOp: res.AllocsPerOp(),
+		BytesPerOp:  res.AllocedBytesPerOp(),
+	}
+}
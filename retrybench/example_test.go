@@ -0,0 +1,16 @@
+package retrybench_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/osvim/retry"
+	"github.com/osvim/retry/retrybench"
+)
+
+func ExampleMeasure() {
+	res := retrybench.Measure(retry.Attempts(3).ExponentialJitterBackoff(100*time.Millisecond, 0.2))
+
+	fmt.Println(res.AllocsPerOp, res.Duration() < time.Millisecond)
+	// Output: 0 true
+}
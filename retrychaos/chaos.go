@@ -0,0 +1,118 @@
+// Package retrychaos injects failures and latency into retry.Func calls,
+// so error classification and timeout budgets can be verified
+// under simulated outages.
+package retrychaos
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/osvim/retry"
+)
+
+// ErrInjected is the default error of injected failures.
+var ErrInjected = errors.New("retrychaos: injected failure")
+
+// Option configures injected faults.
+type Option func(*config)
+
+// WithFailureRate fails the given fraction of calls,
+// rate expected to be in range [0.0, 1.0].
+func WithFailureRate(rate float64) Option {
+	return func(cfg *config) {
+		cfg.failureRate = rate
+	}
+}
+
+// WithFailures fails the first n calls.
+func WithFailures(n int) Option {
+	return WithSchedule(func(call int) bool { return call < n })
+}
+
+// WithSchedule fails calls for which schedule returns true,
+// call is zero-based number of the wrapped Func call.
+func WithSchedule(schedule func(call int) bool) Option {
+	return func(cfg *config) {
+		cfg.schedule = schedule
+	}
+}
+
+// WithLatency delays the given fraction of calls by duration,
+// rate expected to be in range [0.0, 1.0].
+func WithLatency(duration time.Duration, rate float64) Option {
+	return func(cfg *config) {
+		cfg.latency, cfg.latencyRate = duration, rate
+	}
+}
+
+// WithError sets the error of injected failures, ErrInjected by default.
+func WithError(err error) Option {
+	return func(cfg *config) {
+		cfg.err = err
+	}
+}
+
+// WithPermanent makes injected failures permanent,
+// by default they are temporary.
+func WithPermanent() Option {
+	return func(cfg *config) {
+		cfg.permanent = true
+	}
+}
+
+// WithSeed makes injected faults reproducible.
+func WithSeed(seed int64) Option {
+	return func(cfg *config) {
+		cfg.seed = seed
+	}
+}
+
+type config struct {
+	failureRate float64
+	schedule    func(call int) bool
+	latency     time.Duration
+	latencyRate float64
+	err         error
+	permanent   bool
+	seed        int64
+}
+
+// Wrap returns retry.Func injecting faults before call.
+// Injected failure returns (true, ErrInjected) without calling call.
+func Wrap(call retry.Func, opts ...Option) retry.Func {
+	cfg := config{err: ErrInjected, seed: time.Now().UnixNano()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var (
+		calls int64
+		mu    sync.Mutex
+		rnd   = rand.New(rand.NewSource(cfg.seed))
+	)
+	chance := func(rate float64) bool {
+		if rate <= 0 {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return rnd.Float64() < rate
+	}
+
+	return func() (bool, error) {
+		n := int(atomic.AddInt64(&calls, 1) - 1)
+
+		if chance(cfg.latencyRate) {
+			time.Sleep(cfg.latency)
+		}
+
+		if (cfg.schedule != nil && cfg.schedule(n)) || chance(cfg.failureRate) {
+			return !cfg.permanent, cfg.err
+		}
+
+		return call()
+	}
+}
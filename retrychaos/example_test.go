@@ -0,0 +1,34 @@
+package retrychaos_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/osvim/retry"
+	"github.com/osvim/retry/retrychaos"
+)
+
+func ExampleWrap() {
+	var calls int
+
+	call := retrychaos.Wrap(func() (repeat bool, err error) {
+		calls++
+		return false, nil
+	}, retrychaos.WithFailures(2))
+
+	err := retry.Attempts(3).Do(context.TODO(), call)
+
+	fmt.Println(err, calls)
+	// Output: <nil> 1
+}
+
+func ExampleWithFailureRate() {
+	call := retrychaos.Wrap(func() (repeat bool, err error) {
+		return false, nil
+	}, retrychaos.WithFailureRate(1))
+
+	err := retry.Attempts(3).Do(context.TODO(), call)
+
+	fmt.Println(err)
+	// Output: no attempts left: retrychaos: injected failure
+}
@@ -0,0 +1,50 @@
+package retrycompat
+
+import (
+	"time"
+
+	"github.com/osvim/retry"
+)
+
+// Avast mirrors options of github.com/avast/retry-go:
+// retry.Attempts, retry.Delay, retry.MaxDelay and retry.MaxJitter
+// with the default delay type combining exponential and random delays.
+// Zero fields take the defaults of the library.
+type Avast struct {
+	// Attempts is the number of attempts, 10 by default.
+	Attempts uint
+	// Delay is the initial delay, 100ms by default.
+	Delay time.Duration
+	// MaxDelay caps the delay, if positive.
+	MaxDelay time.Duration
+	// MaxJitter is the max random delay added, 100ms by default.
+	MaxJitter time.Duration
+	// Fixed is the retry.FixedDelay delay type, without jitter.
+	Fixed bool
+}
+
+// FromAvast returns Retry of a, the random delay up to MaxJitter is
+// approximated by the symmetric jitter of Retry around Delay+MaxJitter/2.
+func FromAvast(a Avast) retry.Retry {
+	if a.Attempts == 0 {
+		a.Attempts = 10
+	}
+	if a.Delay <= 0 {
+		a.Delay = 100 * time.Millisecond
+	}
+	if a.MaxJitter <= 0 {
+		a.MaxJitter = 100 * time.Millisecond
+	}
+
+	cfg := retry.Config{
+		Attempts:   int(a.Attempts),
+		Backoff:    a.Delay,
+		MaxBackoff: a.MaxDelay,
+	}
+	if !a.Fixed {
+		cfg.Exponential = true
+		cfg.Backoff = a.Delay + a.MaxJitter/2
+		cfg.Jitter = float64(a.MaxJitter) / 2 / float64(cfg.Backoff)
+	}
+	return retry.New(cfg)
+}
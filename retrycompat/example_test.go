@@ -0,0 +1,42 @@
+package retrycompat_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/osvim/retry/retrycompat"
+)
+
+func ExampleFromWaitBackoff() {
+	policy := retrycompat.FromWaitBackoff(retrycompat.WaitBackoff{
+		Duration: 100 * time.Millisecond,
+		Factor:   2,
+		Jitter:   0.5,
+		Steps:    4,
+		Cap:      time.Second,
+	})
+
+	fmt.Println(policy)
+	// Output: 4 attempts, exponential backoff 125ms..500ms, jitter 20%
+}
+
+func ExampleWaitBackOff() {
+	b := retrycompat.WaitBackOff(retrycompat.WaitBackoff{Duration: 100 * time.Millisecond, Factor: 1.5, Steps: 4})
+
+	for i := 0; i < 4; i++ {
+		fmt.Println(b.NextBackOff())
+	}
+	// Output:
+	// 100ms
+	// 150ms
+	// 225ms
+	// -1ns
+}
+
+func ExampleFromAvast() {
+	fmt.Println(retrycompat.FromAvast(retrycompat.Avast{Attempts: 5, Delay: time.Second}))
+	fmt.Println(retrycompat.FromAvast(retrycompat.Avast{Attempts: 3, Delay: time.Second, Fixed: true}))
+	// Output:
+	// 5 attempts, exponential backoff 1.05s..8.4s, jitter 4.762%
+	// 3 attempts, linear backoff 1s
+}
@@ -0,0 +1,89 @@
+// Package retrycompat converts policies of other retry libraries
+// into Retry, easing migration without re-tuning policies.
+// It does not depend on the libraries: their configuration is
+// mirrored by structs of the same fields.
+package retrycompat
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/osvim/retry"
+	"github.com/osvim/retry/backoff"
+)
+
+// WaitBackoff mirrors wait.Backoff of k8s.io/apimachinery/pkg/util/wait,
+// so it is converted by
+//
+//	retrycompat.FromWaitBackoff(retrycompat.WaitBackoff(b))
+type WaitBackoff struct {
+	// Duration is the initial delay.
+	Duration time.Duration
+	// Factor multiplies the delay after each attempt, if greater than 1.
+	Factor float64
+	// Jitter adds a random delay up to Jitter times the delay, if positive.
+	Jitter float64
+	// Steps is the number of attempts.
+	Steps int
+	// Cap caps the delay, if positive.
+	Cap time.Duration
+}
+
+// FromWaitBackoff returns Retry of b: Steps attempts and the delay range
+// of Jitter kept by the symmetric jitter of Retry. Factors greater than 1
+// are approximated by doubling, WaitBackOff keeps any factor exactly.
+func FromWaitBackoff(b WaitBackoff) retry.Retry {
+	attempts := b.Steps
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	// [d, d*(1+j)] is d*(1+j/2) with the symmetric jitter j/(2+j)
+	duration, jitter := b.Duration, 0.0
+	if b.Jitter > 0 {
+		duration = time.Duration(float64(b.Duration) * (1 + b.Jitter/2))
+		jitter = b.Jitter / (2 + b.Jitter)
+	}
+
+	cfg := retry.Config{
+		Attempts:    attempts,
+		Backoff:     duration,
+		Exponential: b.Factor > 1,
+		Jitter:      jitter,
+		MaxBackoff:  b.Cap,
+	}
+	return retry.New(cfg)
+}
+
+// WaitBackOff returns retry.BackOff computing delays as wait.Backoff does,
+// for retry.DoBackOff. It returns retry.Stop after Steps attempts.
+func WaitBackOff(b WaitBackoff) retry.BackOff {
+	return &waitBackOff{initial: b, current: b}
+}
+
+// waitBackOff is retry.BackOff of WaitBackoff
+type waitBackOff struct {
+	initial, current WaitBackoff
+}
+
+func (w *waitBackOff) NextBackOff() time.Duration {
+	b := &w.current
+	// the last step is the last attempt, it is not followed by a delay
+	if b.Steps <= 1 {
+		return retry.Stop
+	}
+	b.Steps--
+
+	delay := b.Duration
+	if b.Factor > 0 {
+		b.Duration = backoff.Capped(time.Duration(float64(b.Duration)*b.Factor), b.Cap)
+	}
+	if b.Jitter > 0 {
+		delay += time.Duration(rand.Float64() * b.Jitter * float64(delay))
+	}
+	return delay
+}
+
+func (w *waitBackOff) Reset() {
+	w.current = w.initial
+}
@@ -0,0 +1,98 @@
+package retrygrpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/osvim/retry"
+	"github.com/osvim/retry/retrygrpc"
+)
+
+func ExamplePushback() {
+	var calls int
+
+	call := func(ctx context.Context) error {
+		calls++
+		// trailer would be received with grpc.Trailer call option
+		trailer := map[string][]string{retrygrpc.PushbackKey: {"-1"}}
+		return retrygrpc.Pushback(errors.New("resource exhausted"), trailer)
+	}
+
+	err := retry.Attempts(5).Backoff(time.Millisecond).DoContext(context.TODO(), retry.Ctx(call, nil))
+
+	fmt.Println(err, calls)
+	// Output: resource exhausted 1
+}
+
+func ExampleParsePushback() {
+	delay, ok, stop := retrygrpc.ParsePushback(map[string][]string{retrygrpc.PushbackKey: {"250"}})
+
+	fmt.Println(delay, ok, stop)
+	// Output: 250ms true false
+}
+
+// code and status mimic codes.Code and *status.Status of grpc-go
+type (
+	code   uint32
+	status struct{ code code }
+)
+
+func (s *status) Code() code { return s.code }
+
+type statusError struct{ status *status }
+
+func (e statusError) Error() string { return fmt.Sprintf("rpc error: code = %d", e.status.code) }
+
+func (e statusError) GRPCStatus() *status { return e.status }
+
+func ExampleFromServiceConfig() {
+	policy, err := retrygrpc.FromServiceConfig([]byte(`{
+		"methodConfig": [{
+			"name": [{"service": "inventory.Stock"}],
+			"retryPolicy": {
+				"maxAttempts": 4,
+				"initialBackoff": "0.01s",
+				"maxBackoff": "0.1s",
+				"backoffMultiplier": 2,
+				"retryableStatusCodes": ["UNAVAILABLE", 8]
+			}
+		}]
+	}`))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(policy)
+
+	// errors are classified by RetryIf of the policy, e.g. in ForEach
+	var calls int
+	errs := retry.ForEach(context.Background(), policy, []string{"sku-1"}, func(ctx context.Context, sku string) error {
+		calls++
+		if calls == 1 {
+			return statusError{&status{code: 14}} // UNAVAILABLE
+		}
+		return statusError{&status{code: 5}} // NOT_FOUND
+	})
+	fmt.Println(calls, errs)
+	// Output:
+	// 4 attempts, exponential backoff 5ms..20ms, jitter 99%
+	// 2 [rpc error: code = 5]
+}
+
+func ExampleFromServiceConfig_multiplier() {
+	policy, err := retrygrpc.FromServiceConfig([]byte(`{
+		"retryPolicy": {
+			"maxAttempts": 10,
+			"initialBackoff": "0.2s",
+			"maxBackoff": "1s",
+			"backoffMultiplier": 1.5,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}`))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(policy)
+	// Output: 5 attempts, exponential backoff 100ms..337.5ms, jitter 99%
+}
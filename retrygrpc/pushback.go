@@ -0,0 +1,56 @@
+// Package retrygrpc adapts gRPC calls to retry policies
+// without depending on grpc-go: metadata.MD is accepted as map[string][]string.
+package retrygrpc
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/osvim/retry"
+)
+
+// PushbackKey is the trailer key of server pushback,
+// see gRPC retry design https://github.com/grpc/proposal/blob/master/A6-client-retries.md
+const PushbackKey = "grpc-retry-pushback-ms"
+
+// Pushback classifies err of a call by the server pushback in its trailer:
+// a non-negative value requests the next attempt after the given milliseconds,
+// a negative or malformed value stops retrying, see retry.RetryAfter and retry.Abort.
+// Without pushback, err is returned as is.
+//
+//	retry.Ctx(func(ctx context.Context) error {
+//		var trailer metadata.MD
+//		_, err := client.Get(ctx, req, grpc.Trailer(&trailer))
+//		return retrygrpc.Pushback(err, trailer)
+//	}, classifier)
+func Pushback(err error, trailer map[string][]string) error {
+	if err == nil {
+		return nil
+	}
+
+	delay, ok, stop := ParsePushback(trailer)
+	switch {
+	case stop:
+		return retry.Abort(err)
+	case ok:
+		return retry.RetryAfter(err, delay)
+	default:
+		return err
+	}
+}
+
+// ParsePushback returns the delay requested by the server pushback in trailer,
+// ok is false without pushback, stop is true when the server asks
+// not to retry: the value is negative or malformed.
+func ParsePushback(trailer map[string][]string) (delay time.Duration, ok, stop bool) {
+	values := trailer[PushbackKey]
+	if len(values) == 0 {
+		return 0, false, false
+	}
+
+	ms, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil || ms < 0 {
+		return 0, false, true
+	}
+	return time.Duration(ms) * time.Millisecond, true, false
+}
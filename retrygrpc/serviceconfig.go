@@ -0,0 +1,177 @@
+package retrygrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osvim/retry"
+)
+
+// codeNames are names of gRPC status codes by their values
+var codeNames = []string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+// maxAttempts is the limit of maxAttempts of gRPC, greater values are
+// treated as 5 by gRPC clients
+const maxAttempts = 5
+
+// retryPolicy is retryPolicy of gRPC service config,
+// see https://github.com/grpc/grpc/blob/master/doc/service_config.md
+type retryPolicy struct {
+	MaxAttempts          int               `json:"maxAttempts"`
+	InitialBackoff       string            `json:"initialBackoff"`
+	MaxBackoff           string            `json:"maxBackoff"`
+	BackoffMultiplier    float64           `json:"backoffMultiplier"`
+	RetryableStatusCodes []json.RawMessage `json:"retryableStatusCodes"`
+}
+
+// serviceConfig is gRPC service config with retry policies
+type serviceConfig struct {
+	RetryPolicy  *retryPolicy `json:"retryPolicy"`
+	MethodConfig []struct {
+		RetryPolicy *retryPolicy `json:"retryPolicy"`
+	} `json:"methodConfig"`
+}
+
+// FromServiceConfig returns Retry of the retryPolicy of gRPC service config,
+// data is either the whole config, the first method config with retryPolicy
+// is used, or the retryPolicy object itself:
+//
+//	{"maxAttempts": 4, "initialBackoff": "0.1s", "maxBackoff": "1s",
+//	 "backoffMultiplier": 2, "retryableStatusCodes": ["UNAVAILABLE"]}
+//
+// Errors are retried when their status code is retryable, see Code.
+// gRPC picks the delay randomly up to the backoff, it is approximated
+// by the jitter of 99% around the half of the backoff. The backoff grows
+// by backoffMultiplier, maxAttempts greater than 5 are limited to 5
+// as gRPC does.
+func FromServiceConfig(data []byte) (retry.Retry, error) {
+	var cfg serviceConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return retry.Retry{}, fmt.Errorf("retrygrpc: %w", err)
+	}
+
+	policy := cfg.RetryPolicy
+	for _, method := range cfg.MethodConfig {
+		if policy == nil {
+			policy = method.RetryPolicy
+		}
+	}
+	if policy == nil {
+		return retry.Retry{}, errors.New("retrygrpc: no retryPolicy in service config")
+	}
+
+	initial, err := parseDuration(policy.InitialBackoff)
+	if err != nil {
+		return retry.Retry{}, err
+	}
+	max, err := parseDuration(policy.MaxBackoff)
+	if err != nil {
+		return retry.Retry{}, err
+	}
+
+	retryable := make(map[uint32]bool, len(policy.RetryableStatusCodes))
+	for _, raw := range policy.RetryableStatusCodes {
+		code, err := parseCode(raw)
+		if err != nil {
+			return retry.Retry{}, err
+		}
+		retryable[code] = true
+	}
+
+	attempts := policy.MaxAttempts
+	if attempts > maxAttempts {
+		attempts = maxAttempts
+	}
+
+	return retry.New(retry.Config{
+		Attempts:    attempts,
+		Backoff:     initial / 2,
+		Exponential: policy.BackoffMultiplier > 0 && policy.BackoffMultiplier != 1,
+		Multiplier:  policy.BackoffMultiplier,
+		Jitter:      0.99,
+		MaxBackoff:  max,
+		RetryIf: func(err error) bool {
+			code, ok := Code(err)
+			return ok && retryable[code]
+		},
+	}), nil
+}
+
+// parseDuration parses durations of service config, e.g. "0.1s"
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("retrygrpc: %w", err)
+	}
+	return d, nil
+}
+
+// parseCode parses a status code given by name, e.g. "UNAVAILABLE", or value
+func parseCode(raw json.RawMessage) (uint32, error) {
+	var name string
+	if err := json.Unmarshal(raw, &name); err != nil {
+		code, err := strconv.ParseUint(string(raw), 10, 32)
+		if err != nil || code >= uint64(len(codeNames)) {
+			return 0, fmt.Errorf("retrygrpc: unknown status code %s", raw)
+		}
+		return uint32(code), nil
+	}
+
+	for code, known := range codeNames {
+		if strings.EqualFold(name, known) {
+			return uint32(code), nil
+		}
+	}
+	return 0, fmt.Errorf("retrygrpc: unknown status code %q", name)
+}
+
+// Code returns the gRPC status code of err, found by the GRPCStatus
+// method of errors of grpc-go in the chain of err, as status.Code does.
+func Code(err error) (code uint32, ok bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		method := reflect.ValueOf(err).MethodByName("GRPCStatus")
+		if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+			continue
+		}
+		status := method.Call(nil)[0]
+		if status.Kind() == reflect.Ptr && status.IsNil() {
+			continue
+		}
+		codeOf := status.MethodByName("Code")
+		if !codeOf.IsValid() || codeOf.Type().NumIn() != 0 || codeOf.Type().NumOut() != 1 {
+			continue
+		}
+		value := codeOf.Call(nil)[0]
+		if value.Kind() != reflect.Uint32 {
+			continue
+		}
+		return uint32(value.Uint()), true
+	}
+	return 0, false
+}
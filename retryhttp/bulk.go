@@ -0,0 +1,55 @@
+package retryhttp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/osvim/retry"
+)
+
+// Bulk submits items to a bulk API answering with a status per item,
+// e.g. Elasticsearch or OpenSearch _bulk, and re-submits only the items
+// rejected with 429 Too Many Requests or 503 Service Unavailable
+// with the backoff of the policy.
+// Submit returns the statuses of the submitted items in the same order,
+// submit failed with a network error is retried.
+// Bulk returns the final status of every item, zero if the item was never
+// answered, and an error if submit failed or rejected items are left
+// after the last attempt.
+func Bulk[T any](ctx context.Context, policy retry.Retry, items []T, submit func(ctx context.Context, items []T) ([]int, error)) ([]int, error) {
+	statuses := make([]int, len(items))
+	pending := make([]int, len(items))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	err := policy.DoContext(ctx, func(ctx context.Context) (bool, error) {
+		batch := make([]T, 0, len(pending))
+		for _, i := range pending {
+			batch = append(batch, items[i])
+		}
+
+		answered, err := submit(ctx, batch)
+		if err != nil {
+			return retryableError(ctx, err, RetryableError), err
+		}
+		if len(answered) != len(batch) {
+			return false, fmt.Errorf("retryhttp: %d statuses of %d bulk items", len(answered), len(batch))
+		}
+
+		rejected := pending[:0]
+		for j, i := range pending {
+			statuses[i] = answered[j]
+			if RetryableStatus(answered[j]) {
+				rejected = append(rejected, i)
+			}
+		}
+		pending = rejected
+
+		if len(pending) > 0 {
+			return true, fmt.Errorf("retryhttp: %d of %d bulk items rejected", len(pending), len(items))
+		}
+		return false, nil
+	})
+	return statuses, err
+}
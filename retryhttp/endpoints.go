@@ -0,0 +1,65 @@
+package retryhttp
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Failover is the order of endpoints tried by retries, see WithEndpoints.
+type Failover int
+
+const (
+	// RoundRobin starts each request at the next endpoint, spreading
+	// requests across endpoints, and each retry targets the next one.
+	RoundRobin Failover = iota
+	// PriorityOrder starts each request at the first endpoint, and each
+	// retry fails over to the next one, e.g. from a primary to replicas.
+	PriorityOrder
+)
+
+// endpoint is the scheme and host of an endpoint
+type endpoint struct {
+	scheme, host string
+}
+
+// WithEndpoints makes Transport send each attempt to the next of endpoints
+// in the order of failover, turning retries into client-side failover.
+// An endpoint is a host, e.g. "10.0.0.1:8080", or scheme and host, e.g.
+// "https://replica.example.com", replacing those of the request URL.
+func WithEndpoints(endpoints []string, failover Failover) Option {
+	return func(t *Transport) {
+		t.endpoints = t.endpoints[:0]
+		for _, e := range endpoints {
+			scheme, host := "", e
+			if i := strings.Index(e, "://"); i >= 0 {
+				scheme, host = e[:i], e[i+3:]
+			}
+			t.endpoints = append(t.endpoints, endpoint{scheme: scheme, host: host})
+		}
+		t.failover = failover
+	}
+}
+
+// first returns the index of the endpoint of the first attempt of a request
+func (t *Transport) first() int {
+	if t.failover != RoundRobin || len(t.endpoints) == 0 {
+		return 0
+	}
+	return int((atomic.AddUint64(&t.next, 1) - 1) % uint64(len(t.endpoints)))
+}
+
+// target returns the host of attempt of a request, whose first attempt
+// targeted the endpoint of index first, req is adjusted if endpoints are set
+func (t *Transport) target(req *http.Request, first, attempt int) string {
+	if len(t.endpoints) == 0 {
+		return req.URL.Host
+	}
+
+	e := t.endpoints[(first+attempt)%len(t.endpoints)]
+	if e.scheme != "" {
+		req.URL.Scheme = e.scheme
+	}
+	req.URL.Host, req.Host = e.host, ""
+	return e.host
+}
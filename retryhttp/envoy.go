@@ -0,0 +1,150 @@
+package retryhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/osvim/retry"
+)
+
+// envoyRetryPolicy is retry_policy of Envoy route,
+// see https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/route/v3/route_components.proto#config-route-v3-retrypolicy
+type envoyRetryPolicy struct {
+	RetryOn              string `json:"retry_on"`
+	NumRetries           *int   `json:"num_retries"`
+	PerTryTimeout        string `json:"per_try_timeout"`
+	RetriableStatusCodes []int  `json:"retriable_status_codes"`
+	RetryBackOff         *struct {
+		BaseInterval string `json:"base_interval"`
+		MaxInterval  string `json:"max_interval"`
+	} `json:"retry_back_off"`
+}
+
+// EnvoyPolicy is the equivalent of Envoy route retry_policy,
+// so application retries mirror the retries of the mesh.
+type EnvoyPolicy struct {
+	// Policy has num_retries + 1 attempts limited by per_try_timeout,
+	// Envoy picks the delay randomly up to the exponential backoff,
+	// it is approximated by the jitter of 99% around the half of it.
+	Policy retry.Retry
+	// statuses are status codes retried by retry_on
+	statuses map[int]bool
+	// server5xx retries every 5xx status
+	server5xx bool
+	// network retries resets and connection failures
+	network bool
+}
+
+// ParseEnvoyRetryPolicy parses retry_policy of Envoy route or Istio
+// VirtualService translated to Envoy configuration, given in JSON:
+//
+//	{"retry_on": "5xx,reset", "num_retries": 3, "per_try_timeout": "2s"}
+//
+// HTTP conditions of retry_on are supported: 5xx, gateway-error, reset,
+// connect-failure, refused-stream, retriable-4xx, retriable-status-codes
+// and envoy-ratelimited, other conditions, e.g. of gRPC, are ignored.
+func ParseEnvoyRetryPolicy(data []byte) (EnvoyPolicy, error) {
+	var cfg envoyRetryPolicy
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return EnvoyPolicy{}, fmt.Errorf("retryhttp: %w", err)
+	}
+
+	p := EnvoyPolicy{statuses: make(map[int]bool)}
+	for _, on := range strings.Split(cfg.RetryOn, ",") {
+		switch strings.TrimSpace(on) {
+		case "5xx":
+			p.server5xx, p.network = true, true
+		case "gateway-error":
+			p.statuses[http.StatusBadGateway] = true
+			p.statuses[http.StatusServiceUnavailable] = true
+			p.statuses[http.StatusGatewayTimeout] = true
+		case "reset", "connect-failure", "refused-stream":
+			p.network = true
+		case "retriable-4xx":
+			p.statuses[http.StatusConflict] = true
+		case "envoy-ratelimited":
+			p.statuses[http.StatusTooManyRequests] = true
+		case "retriable-status-codes":
+			for _, code := range cfg.RetriableStatusCodes {
+				p.statuses[code] = true
+			}
+		}
+	}
+
+	retries := 1
+	if cfg.NumRetries != nil {
+		retries = *cfg.NumRetries
+	}
+
+	base, max := 25*time.Millisecond, time.Duration(0)
+	if cfg.RetryBackOff != nil {
+		var err error
+		if base, err = envoyDuration(cfg.RetryBackOff.BaseInterval, base); err != nil {
+			return EnvoyPolicy{}, err
+		}
+		if max, err = envoyDuration(cfg.RetryBackOff.MaxInterval, 0); err != nil {
+			return EnvoyPolicy{}, err
+		}
+	}
+	if max <= 0 {
+		max = 10 * base
+	}
+	perTry, err := envoyDuration(cfg.PerTryTimeout, 0)
+	if err != nil {
+		return EnvoyPolicy{}, err
+	}
+
+	p.Policy = retry.New(retry.Config{
+		Attempts:       retries + 1,
+		Backoff:        base / 2,
+		Exponential:    true,
+		Jitter:         0.99,
+		MaxBackoff:     max,
+		AttemptTimeout: perTry,
+		RetryIf:        p.Retryable,
+	})
+	return p, nil
+}
+
+// envoyDuration parses a duration of Envoy configuration, e.g. "0.25s"
+func envoyDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("retryhttp: %w", err)
+	}
+	return d, nil
+}
+
+// RetryableStatus reports whether responses with statusCode are retried by retry_on.
+func (p EnvoyPolicy) RetryableStatus(statusCode int) bool {
+	return p.statuses[statusCode] || p.server5xx && statusCode >= 500 && statusCode <= 599
+}
+
+// Retryable is the classifier of errors by retry_on: StatusError
+// by RetryableStatus, other errors are network ones and retried
+// by reset, connect-failure, refused-stream and 5xx.
+func (p EnvoyPolicy) Retryable(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return p.RetryableStatus(status.StatusCode)
+	}
+	return p.network
+}
+
+// RetryableError reports whether failed round trips are retried by retry_on:
+// network errors by reset, connect-failure, refused-stream and 5xx.
+func (p EnvoyPolicy) RetryableError(err error) bool {
+	return p.network && RetryableError(err)
+}
+
+// Options returns Options of Transport retrying with the policy.
+func (p EnvoyPolicy) Options() []Option {
+	return []Option{WithPolicy(p.Policy), WithRetryableStatus(p.RetryableStatus), WithRetryableError(p.RetryableError)}
+}
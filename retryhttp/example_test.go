@@ -0,0 +1,377 @@
+package retryhttp_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/osvim/retry"
+	"github.com/osvim/retry/retryhttp"
+)
+
+func ExampleNewClient() {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	client := retryhttp.NewClient(retryhttp.WithPolicy(retry.Attempts(3).Backoff(time.Millisecond)))
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	fmt.Println(resp.StatusCode, string(body), calls)
+	// Output: 200 ok 3
+}
+
+func ExampleRetryAfter() {
+	header := http.Header{"Retry-After": {"120"}}
+
+	delay, ok := retryhttp.RetryAfter(header, time.Now())
+
+	fmt.Println(delay, ok)
+	// Output: 2m0s true
+}
+
+func ExampleAllowNonIdempotent() {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	policy := retryhttp.WithPolicy(retry.Attempts(3))
+
+	resp, _ := retryhttp.NewClient(policy).Post(server.URL, "text/plain", nil)
+	resp.Body.Close()
+	fmt.Println(resp.StatusCode, calls)
+
+	calls = 0
+	resp, _ = retryhttp.NewClient(policy, retryhttp.AllowNonIdempotent()).Post(server.URL, "text/plain", nil)
+	resp.Body.Close()
+	fmt.Println(resp.StatusCode, calls)
+
+	// Output:
+	// 503 1
+	// 503 3
+}
+
+func ExampleHandler() {
+	var calls int
+	flaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 2 {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	})
+
+	server := httptest.NewServer(retryhttp.Handler(flaky, retry.Attempts(3)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	fmt.Println(resp.StatusCode, string(body), calls)
+	// Output: 200 ok 2
+}
+
+func ExampleBulk() {
+	docs := []string{"a", "b", "c"}
+	var submitted [][]string
+
+	statuses, err := retryhttp.Bulk(context.TODO(), retry.Attempts(3).Backoff(time.Millisecond), docs,
+		func(ctx context.Context, batch []string) ([]int, error) {
+			submitted = append(submitted, batch)
+
+			statuses := make([]int, len(batch))
+			for i, doc := range batch {
+				statuses[i] = http.StatusCreated
+				if doc == "b" && len(submitted) < 2 {
+					statuses[i] = http.StatusTooManyRequests
+				}
+			}
+			return statuses, nil
+		})
+
+	fmt.Println(statuses, err, submitted)
+	// Output: [201 201 201] <nil> [[a b c] [b]]
+}
+
+func ExampleParseEnvoyRetryPolicy() {
+	envoy, err := retryhttp.ParseEnvoyRetryPolicy([]byte(`{
+		"retry_on": "gateway-error,retriable-status-codes",
+		"retriable_status_codes": [409],
+		"num_retries": 2,
+		"per_try_timeout": "1s",
+		"retry_back_off": {"base_interval": "0.01s"}
+	}`))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(envoy.Policy)
+
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	resp, err := retryhttp.NewClient(envoy.Options()...).Get(server.URL)
+	if err != nil {
+		panic(err)
+	}
+	_ = resp.Body.Close()
+
+	fmt.Println(resp.StatusCode, calls)
+	// Output:
+	// 3 attempts, exponential backoff 5ms..10ms, jitter 99%, attempt timeout 1s
+	// 200 3
+}
+
+func ExampleWithMaxBodyBuffer() {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := retryhttp.NewClient(
+		retryhttp.WithPolicy(retry.Attempts(3).Backoff(time.Millisecond)),
+		retryhttp.WithMaxBodyBuffer(1<<10),
+	)
+
+	// io.MultiReader hides the type of the body, so GetBody is not set
+	body := io.MultiReader(strings.NewReader(`{"stock":`), strings.NewReader(`42}`))
+	req, _ := http.NewRequest(http.MethodPut, server.URL, body)
+	resp, err := client.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	_ = resp.Body.Close()
+	fmt.Println(resp.StatusCode, bodies)
+
+	large := io.MultiReader(strings.NewReader(strings.Repeat("x", 2<<10)))
+	req, _ = http.NewRequest(http.MethodPut, server.URL, large)
+	_, err = client.Do(req)
+	fmt.Println(errors.Is(err, retryhttp.ErrBodyNotRewindable))
+	// Output:
+	// 200 [{"stock":42} {"stock":42}]
+	// true
+}
+
+func ExampleWithHostBudget() {
+	var failingCalls, healthyCalls int
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failingCalls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyCalls++
+		if healthyCalls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer healthy.Close()
+
+	client := retryhttp.NewClient(
+		retryhttp.WithPolicy(retry.Attempts(3).Backoff(time.Millisecond)),
+		retryhttp.WithHostBudget(1),
+	)
+	for i := 0; i < 2; i++ {
+		resp, _ := client.Get(failing.URL)
+		_ = resp.Body.Close()
+	}
+	resp, _ := client.Get(healthy.URL)
+	_ = resp.Body.Close()
+
+	// the budget of the failing host allows one retry per second
+	fmt.Println(failingCalls, healthyCalls, resp.StatusCode)
+	// Output: 3 2 200
+}
+
+func ExampleWithEndpoints() {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	replica := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "served by replica "+r.URL.Path)
+	}))
+	defer replica.Close()
+
+	client := retryhttp.NewClient(
+		retryhttp.WithPolicy(retry.Attempts(3).Backoff(time.Millisecond)),
+		retryhttp.WithEndpoints([]string{primary.URL, replica.URL}, retryhttp.PriorityOrder),
+	)
+
+	resp, err := client.Get("http://inventory/stock")
+	if err != nil {
+		panic(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	fmt.Println(string(body))
+	// Output: served by replica /stock
+}
+
+func ExampleWithReresolve() {
+	var calls, connections int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections++
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := retryhttp.NewClient(
+		retryhttp.WithPolicy(retry.Attempts(3).Backoff(time.Millisecond)),
+		retryhttp.WithReresolve(),
+	)
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		panic(err)
+	}
+	_ = resp.Body.Close()
+
+	// every attempt dialed the server again, resolving its hostname
+	fmt.Println(resp.StatusCode, connections)
+	// Output: 200 3
+}
+
+func ExampleTLSRetryable() {
+	var handshakes int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			handshakes++
+		}
+	}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	// the certificate of the test server is not trusted by the client
+	client := retryhttp.NewClient(retryhttp.WithPolicy(retry.Attempts(3).Backoff(time.Millisecond)))
+	_, err := client.Get(server.URL)
+
+	fmt.Println(retryhttp.TLSRetryable(err), handshakes)
+	// Output: false 1
+}
+
+func ExampleWithPolicy_attemptTimeout() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the headers arrive in time, the body is streamed slowly
+		w.WriteHeader(http.StatusOK)
+		for _, chunk := range []string{"one ", "two ", "three"} {
+			_, _ = io.WriteString(w, chunk)
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	// the attempt timeout limits waiting for the response headers,
+	// but not reading the body
+	client := retryhttp.NewClient(retryhttp.WithPolicy(
+		retry.Attempts(3).AttemptTimeout(30 * time.Millisecond),
+	))
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	fmt.Println(string(body), err)
+	// Output: one two three <nil>
+}
+
+func ExampleNewClient_largeBody() {
+	page := strings.Repeat("maintenance ", 10000)
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, page)
+	}))
+	defer server.Close()
+
+	// the body is too large to drain, so the response is returned as is
+	client := retryhttp.NewClient(retryhttp.WithPolicy(retry.Attempts(3).Backoff(time.Millisecond)))
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	fmt.Println(resp.StatusCode, calls, string(body) == page, err)
+	// Output: 503 1 true <nil>
+}
+
+func ExampleEnvoyPolicy_Options() {
+	envoy, err := retryhttp.ParseEnvoyRetryPolicy([]byte(`{"retry_on": "retriable-4xx", "num_retries": 2}`))
+	if err != nil {
+		panic(err)
+	}
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		// the connection is closed without a response
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		_ = conn.Close()
+	}))
+	defer server.Close()
+
+	// network errors are not retried by retriable-4xx
+	_, err = retryhttp.NewClient(envoy.Options()...).Get(server.URL)
+	fmt.Println(err != nil, atomic.LoadInt32(&calls))
+	// Output: true 1
+}
@@ -0,0 +1,117 @@
+package retryhttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/osvim/retry"
+)
+
+// maxHandlerBody is the max size of a request body buffered by Handler.
+const maxHandlerBody = 1 << 20
+
+// Handler returns http.Handler calling next again with the policy,
+// when it responds with 5xx status to an idempotent request, see Idempotent,
+// e.g. in front of a flaky internal reverse proxy.
+// Responses of next are buffered in memory and written when retries are over.
+// Requests with a body larger than 1MiB are not retried.
+func Handler(next http.Handler, policy retry.Retry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !Idempotent(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, ok := bufferBody(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var rec *recorder
+		_ = policy.DoContext(r.Context(), func(ctx context.Context) (bool, error) {
+			req := r.Clone(ctx)
+			if body != nil {
+				req.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			rec = newRecorder()
+			next.ServeHTTP(rec, req)
+			if rec.status >= http.StatusInternalServerError {
+				return true, &StatusError{StatusCode: rec.status}
+			}
+			return false, nil
+		})
+
+		if rec != nil {
+			rec.writeTo(w)
+		} else {
+			// the context is done before the first attempt
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// bufferBody reads the body of r, ok is false if it is too large,
+// in this case r.Body is restored
+func bufferBody(r *http.Request) (body []byte, ok bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHandlerBody+1))
+	if err != nil || len(body) > maxHandlerBody {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, false
+	}
+	return body, true
+}
+
+// recorder is http.ResponseWriter buffering the response
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: make(http.Header)}
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *recorder) Write(data []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(data)
+}
+
+// writeTo writes the buffered response to w
+func (rec *recorder) writeTo(w http.ResponseWriter) {
+	for key, values := range rec.header {
+		w.Header()[key] = values
+	}
+	if rec.header.Get("Content-Length") == "" {
+		w.Header().Set("Content-Length", strconv.Itoa(rec.body.Len()))
+	}
+
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, _ = rec.body.WriteTo(w)
+}
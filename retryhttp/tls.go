@@ -0,0 +1,53 @@
+package retryhttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/osvim/retry"
+)
+
+// connReset classifies connections closed or refused by the peer
+// on every platform, see retry.ConnErrors
+var connReset = retry.OnConnReset()
+
+// TLSRetryable reports whether a request failed with err, e.g. during
+// TLS handshake, can be retried: timeouts, connections closed or refused
+// by the peer, see retry.ConnErrors, and unexpected EOFs are temporary, while certificate verification errors
+// and protocol mismatches are permanent, retrying them is pointless.
+// It is a retry.Classifier, Transport doesn't retry permanent TLS errors.
+func TLSRetryable(err error) bool {
+	if permanentTLS(err) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return connReset(err) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// permanentTLS reports whether err is a certificate verification
+// or TLS protocol error
+func permanentTLS(err error) bool {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		invalid          x509.CertificateInvalidError
+		hostname         x509.HostnameError
+		insecure         x509.InsecureAlgorithmError
+		constraint       x509.ConstraintViolationError
+		record           tls.RecordHeaderError
+	)
+	return errors.As(err, &unknownAuthority) ||
+		errors.As(err, &invalid) ||
+		errors.As(err, &hostname) ||
+		errors.As(err, &insecure) ||
+		errors.As(err, &constraint) ||
+		errors.As(err, &record)
+}
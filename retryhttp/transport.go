@@ -0,0 +1,465 @@
+// Package retryhttp provides http.RoundTripper retrying requests
+// on throttling, unavailability and network errors.
+package retryhttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/osvim/retry"
+)
+
+// DefaultPolicy is the policy of Transport created without WithPolicy.
+var DefaultPolicy = retry.Attempts(4).ExponentialJitterBackoff(250*time.Millisecond, 0.2)
+
+// DefaultMaxElapsed is the max total time of a request created without WithMaxElapsed.
+const DefaultMaxElapsed = 30 * time.Second
+
+// DefaultMaxBodyBuffer is the max size of a request body buffered
+// for retries by Transport created without WithMaxBodyBuffer.
+const DefaultMaxBodyBuffer = 64 << 10
+
+// ErrBodyNotRewindable is returned by Transport for a retryable request
+// with a body that can't be sent again: http.Request.GetBody is not set
+// and the body is larger than the max body buffer.
+var ErrBodyNotRewindable = errors.New("retryhttp: request body is too large to buffer for retries, set http.Request.GetBody")
+
+// maxDrain is the max size of a response body read into memory
+// to release the connection before the next attempt.
+const maxDrain = 64 << 10
+
+// Option configures Transport.
+type Option func(*Transport)
+
+// WithPolicy sets the policy of retrying requests, DefaultPolicy by default.
+func WithPolicy(policy retry.Retry) Option {
+	return func(t *Transport) {
+		t.policy = policy
+	}
+}
+
+// WithMaxElapsed caps the total time of a request including retries
+// and reading the response body, DefaultMaxElapsed by default.
+// Non-positive value removes the cap.
+func WithMaxElapsed(maxElapsed time.Duration) Option {
+	return func(t *Transport) {
+		t.maxElapsed = maxElapsed
+	}
+}
+
+// AllowNonIdempotent makes Transport retry requests with non-idempotent
+// methods, e.g. POST, which may cause duplicate side effects.
+func AllowNonIdempotent() Option {
+	return func(t *Transport) {
+		t.allowNonIdempotent = true
+	}
+}
+
+// WithMaxBodyBuffer sets the max size of a request body without
+// http.Request.GetBody buffered in memory, so it can be sent again
+// by retries, DefaultMaxBodyBuffer by default. Non-positive value
+// disables buffering, see ErrBodyNotRewindable.
+func WithMaxBodyBuffer(max int64) Option {
+	return func(t *Transport) {
+		t.maxBodyBuffer = max
+	}
+}
+
+// WithHostBudget limits retries of requests to each host to
+// retriesPerSecond, so a failing upstream exhausting its budget
+// doesn't disable retries to healthy hosts. When the budget of the host
+// is exceeded, the last response is returned, or the error of the last
+// attempt matched by retry.ErrBudgetExceeded.
+func WithHostBudget(retriesPerSecond float64) Option {
+	return func(t *Transport) {
+		t.hostBudget = retriesPerSecond
+	}
+}
+
+// WithReresolve sends retries over new connections, so the dialer
+// resolves the hostname again and retries after a DNS failover reach
+// the new addresses instead of connections to dead ones: connections
+// of attempts are not reused, and idle connections of the base transport
+// are closed before each retry, if it has CloseIdleConnections.
+func WithReresolve() Option {
+	return func(t *Transport) {
+		t.reresolve = true
+	}
+}
+
+// WithRetryableStatus sets the classifier of response status codes,
+// RetryableStatus by default.
+func WithRetryableStatus(retryable func(statusCode int) bool) Option {
+	return func(t *Transport) {
+		t.retryableStatus = retryable
+	}
+}
+
+// WithRetryableError sets the classifier of failed round trips,
+// RetryableError by default. Errors of done contexts and TLS
+// verification errors are never retried.
+func WithRetryableError(retryable func(err error) bool) Option {
+	return func(t *Transport) {
+		t.retryableError = retryable
+	}
+}
+
+// Transport is http.RoundTripper retrying requests answered with
+// 429 Too Many Requests or 503 Service Unavailable, see WithRetryableStatus,
+// or failed with a network error, see WithRetryableError.
+// Retry-After header of the response takes precedence over the backoff of the policy.
+// Response bodies of retried attempts are drained and closed,
+// so connections are reused. A response with a body larger than 64KiB is
+// returned without retries.
+// Request bodies are sent again by http.Request.GetBody, bodies without it
+// are buffered in memory up to the max body buffer, larger ones fail
+// with ErrBodyNotRewindable before the first attempt.
+// Only requests with idempotent methods (GET, HEAD, PUT, DELETE, OPTIONS, TRACE)
+// or Idempotency-Key header are retried, see AllowNonIdempotent.
+type Transport struct {
+	base               http.RoundTripper
+	policy             retry.Retry
+	maxElapsed         time.Duration
+	allowNonIdempotent bool
+	retryableStatus    func(statusCode int) bool
+	retryableError     func(err error) bool
+	maxBodyBuffer      int64
+	hostBudget         float64
+	reresolve          bool
+	endpoints          []endpoint
+	failover           Failover
+	// next is the counter of requests started at endpoints by RoundRobin
+	next uint64
+	// budgets are retry.Budget of hosts, if hostBudget is set
+	budgets sync.Map
+}
+
+// NewTransport returns Transport wrapping base, http.DefaultTransport if nil.
+func NewTransport(base http.RoundTripper, opts ...Option) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	t := &Transport{
+		base:            base,
+		policy:          DefaultPolicy,
+		maxElapsed:      DefaultMaxElapsed,
+		retryableStatus: RetryableStatus,
+		retryableError:  RetryableError,
+		maxBodyBuffer:   DefaultMaxBodyBuffer,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.retryableStatus == nil {
+		t.retryableStatus = RetryableStatus
+	}
+	if t.retryableError == nil {
+		t.retryableError = RetryableError
+	}
+	return t
+}
+
+// NewClient returns http.Client with Transport wrapping http.DefaultTransport.
+func NewClient(opts ...Option) *http.Client {
+	return &http.Client{Transport: NewTransport(nil, opts...)}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	first := t.first()
+	if !t.allowNonIdempotent && !Idempotent(req) {
+		if len(t.endpoints) > 0 {
+			req = req.Clone(req.Context())
+			t.target(req, first, 0)
+		}
+		return t.base.RoundTrip(req)
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// the body can't be sent twice unless buffered
+		var err error
+		if req, err = t.bufferBody(req); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if t.maxElapsed > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.maxElapsed)
+	}
+
+	var (
+		resp       *http.Response
+		respCancel context.CancelFunc
+		lastErr    error
+		attempt    int
+	)
+	parent := ctx
+	err := t.policy.DoContext(ctx, func(ctx context.Context) (bool, error) {
+		defer func() { attempt++ }()
+
+		attemptReq := req.Clone(ctx)
+		host := t.target(attemptReq, first, attempt)
+		if attempt > 0 && !t.allowRetry(host) {
+			if resp != nil {
+				return false, nil
+			}
+			return false, fmt.Errorf("%w for %s: %v", retry.ErrBudgetExceeded, host, lastErr)
+		}
+
+		attemptReq, err := rewind(attemptReq, attempt)
+		if err != nil {
+			return false, err
+		}
+		if t.reresolve {
+			t.closeIdle(attempt)
+			attemptReq.Close = true
+		}
+
+		if respCancel != nil {
+			respCancel()
+		}
+		if resp, respCancel, err = t.roundTrip(parent, ctx, attemptReq); err != nil {
+			lastErr = err
+			return retryableError(ctx, err, t.retryableError), err
+		}
+		return classify(resp, t.retryableStatus)
+	})
+
+	switch {
+	case resp != nil:
+		// the last response is returned as is, even if attempts are exhausted
+		resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: func() {
+			respCancel()
+			cancel()
+		}}
+		return resp, nil
+	case err != nil:
+		if respCancel != nil {
+			respCancel()
+		}
+		cancel()
+		return nil, err
+	default:
+		cancel()
+		return nil, errors.New("retryhttp: no attempts made")
+	}
+}
+
+// roundTrip sends req by the base transport in the context of parent,
+// so the body of the response outlives the attempt context, which is
+// cancelled when the attempt returns. The attempt context interrupts
+// the request until the response headers are received, e.g. by the attempt
+// timeout of the policy. Cancel releases the request, e.g. when the body
+// is closed, it is nil if err is not nil.
+func (t *Transport) roundTrip(parent, attempt context.Context, req *http.Request) (resp *http.Response, cancel context.CancelFunc, err error) {
+	ctx, cancel := context.WithCancel(parent)
+	received := make(chan struct{})
+	interrupted := make(chan bool, 1)
+	go func() {
+		select {
+		case <-attempt.Done():
+			cancel()
+			interrupted <- true
+		case <-received:
+			interrupted <- false
+		}
+	}()
+
+	resp, err = t.base.RoundTrip(req.WithContext(ctx))
+	close(received)
+	if <-interrupted {
+		// the attempt is over, even if the response came just in time
+		if err == nil {
+			resp.Body.Close()
+		}
+		resp, err = nil, attempt.Err()
+	}
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return resp, cancel, nil
+}
+
+// closeIdle closes idle connections of the base transport before a retry
+func (t *Transport) closeIdle(attempt int) {
+	if attempt == 0 {
+		return
+	}
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// allowRetry reports whether the budget of host allows one more retry
+func (t *Transport) allowRetry(host string) bool {
+	if t.hostBudget <= 0 {
+		return true
+	}
+	budget, ok := t.budgets.Load(host)
+	if !ok {
+		budget, _ = t.budgets.LoadOrStore(host, retry.NewBudget(t.hostBudget))
+	}
+	return budget.(*retry.Budget).Allow()
+}
+
+// bufferBody returns a copy of req with the body buffered in memory
+// and GetBody set, the body of req is closed
+func (t *Transport) bufferBody(req *http.Request) (*http.Request, error) {
+	defer req.Body.Close()
+
+	if t.maxBodyBuffer <= 0 || req.ContentLength > t.maxBodyBuffer {
+		return nil, ErrBodyNotRewindable
+	}
+	data, err := io.ReadAll(io.LimitReader(req.Body, t.maxBodyBuffer+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > t.maxBodyBuffer {
+		return nil, ErrBodyNotRewindable
+	}
+
+	buffered := req.Clone(req.Context())
+	buffered.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	buffered.Body, _ = buffered.GetBody()
+	buffered.ContentLength = int64(len(data))
+	return buffered, nil
+}
+
+// rewind sets the body of a retried request
+func rewind(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = body
+	return req, nil
+}
+
+// classify returns whether resp should be retried,
+// its body is drained and replaced with in-memory copy, if so.
+func classify(resp *http.Response, retryableStatus func(statusCode int) bool) (bool, error) {
+	if !retryableStatus(resp.StatusCode) {
+		return false, nil
+	}
+
+	if err := drain(resp); err != nil {
+		return false, nil
+	}
+
+	err := &StatusError{StatusCode: resp.StatusCode}
+	if delay, ok := RetryAfter(resp.Header, time.Now()); ok {
+		return true, retry.RetryAfter(err, delay)
+	}
+	return true, err
+}
+
+// drain reads the body of resp into memory and closes it,
+// so the connection can be reused by the next attempt.
+// If it fails, the read bytes are put back, so the body is intact.
+func drain(resp *http.Response) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDrain+1))
+	if err == nil && len(data) > maxDrain {
+		err = errors.New("retryhttp: response body is too large to drain")
+	}
+	if err != nil {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+		return err
+	}
+
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return nil
+}
+
+// Idempotent reports whether req can be retried without duplicate side effects:
+// its method is idempotent by RFC 9110 or it has Idempotency-Key header.
+func Idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// RetryableStatus reports whether the response with statusCode should be retried.
+func RetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+}
+
+// retryableError reports whether a failed round trip should be retried,
+// classified by retryable unless ctx is done or TLS verification failed
+func retryableError(ctx context.Context, err error, retryable func(err error) bool) bool {
+	if ctx.Err() != nil || permanentTLS(err) {
+		return false
+	}
+	return retryable(err)
+}
+
+// RetryableError reports whether a failed round trip should be retried:
+// network errors and connections closed mid-response.
+func RetryableError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// RetryAfter parses Retry-After header of a response received at now:
+// either delay in seconds or HTTP date.
+func RetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// StatusError is the error of a retried response.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return "retryhttp: " + strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+}
+
+// cancelOnClose cancels the context of a request when the response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,41 @@
+package retrymetrics_test
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"time"
+
+	"github.com/osvim/retry"
+	"github.com/osvim/retry/retrymetrics"
+	"github.com/osvim/retry/retrytest"
+)
+
+func ExampleNew() {
+	recorder := retrymetrics.New("payments")
+	policy := retry.Attempts(4).
+		ExponentialBackoff(10 * time.Millisecond).
+		Clock(retrytest.NewClock(time.Now())).
+		Recorder(recorder)
+
+	err := policy.Do(context.TODO(), func() (bool, error) {
+		return true, errors.New("unavailable")
+	})
+	fmt.Println(err)
+
+	delays := recorder.Delays()
+	fmt.Println(delays.Count, delays.Sum)
+	for _, bucket := range delays.Buckets[1:5] {
+		fmt.Println(bucket.Le, bucket.Count)
+	}
+	fmt.Println(expvar.Get("retry").(*expvar.Map).Get("payments"))
+	// Output:
+	// no attempts left: unavailable
+	// 3 70ms
+	// 5ms 0
+	// 10ms 1
+	// 25ms 2
+	// 50ms 3
+	// {"attempts": 4, "delay_seconds": {"0.001": 0, "0.005": 0, "0.01": 1, "0.025": 2, "0.05": 3, "0.1": 3, "0.25": 3, "0.5": 3, "1": 3, "2.5": 3, "5": 3, "10": 3, "30": 3, "60": 3, "+Inf": 3, "count": 3, "sum": 0.07}, "exhausted": 1, "failures": 4}
+}
@@ -0,0 +1,183 @@
+// Package retrymetrics publishes metrics of retry policies by expvar,
+// so they are served at /debug/vars next to the runtime ones:
+//
+//	policy := retry.Attempts(5).ExponentialJitterBackoff(100*time.Millisecond, 0.2).
+//		Recorder(retrymetrics.New("payments"))
+//
+// Metrics of policies are published in the "retry" map by policy name:
+// the counters of attempts, failures and exhausted calls, and the histogram
+// of delays slept in backoff, after jitter.
+package retrymetrics
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/osvim/retry"
+)
+
+// DelayBuckets are the upper bounds of the buckets of delay histograms.
+var DelayBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+// published is the expvar map of metrics by policy name
+var published = expvar.NewMap("retry")
+
+var (
+	mu        sync.Mutex
+	recorders = make(map[string]*Recorder)
+)
+
+// Recorder is retry.Recorder publishing metrics of a policy,
+// it is safe for concurrent use.
+type Recorder struct {
+	attempts  expvar.Int
+	failures  expvar.Int
+	exhausted expvar.Int
+	delays    *histogram
+}
+
+// New returns Recorder of the policy name, recorders of the same name
+// share the metrics. Histograms use DelayBuckets of the first call.
+func New(name string) *Recorder {
+	mu.Lock()
+	defer mu.Unlock()
+	if r, ok := recorders[name]; ok {
+		return r
+	}
+
+	r := &Recorder{delays: newHistogram(DelayBuckets)}
+	metrics := new(expvar.Map)
+	metrics.Set("attempts", &r.attempts)
+	metrics.Set("failures", &r.failures)
+	metrics.Set("exhausted", &r.exhausted)
+	metrics.Set("delay_seconds", r.delays)
+	published.Set(name, metrics)
+	recorders[name] = r
+	return r
+}
+
+// AttemptStarted implements retry.Recorder.
+func (r *Recorder) AttemptStarted(ctx context.Context, attempt int) {
+	r.attempts.Add(1)
+}
+
+// AttemptEnded implements retry.Recorder.
+func (r *Recorder) AttemptEnded(ctx context.Context, attempt int, err error, duration time.Duration) {
+	if err != nil {
+		r.failures.Add(1)
+	}
+}
+
+// Exhausted implements retry.Recorder.
+func (r *Recorder) Exhausted(ctx context.Context, attempts int, err error) {
+	r.exhausted.Add(1)
+}
+
+// Slept implements retry.DelayRecorder.
+func (r *Recorder) Slept(ctx context.Context, attempt int, slept time.Duration) {
+	r.delays.observe(slept)
+}
+
+// Delays returns the snapshot of the histogram of slept delays.
+func (r *Recorder) Delays() Histogram {
+	return r.delays.snapshot()
+}
+
+var (
+	_ retry.Recorder      = (*Recorder)(nil)
+	_ retry.DelayRecorder = (*Recorder)(nil)
+)
+
+// Histogram is a snapshot of a histogram of delays.
+type Histogram struct {
+	// Buckets are cumulative: a bucket counts delays not longer
+	// than its bound, including delays of the previous buckets.
+	Buckets []Bucket
+	// Count is the number of delays, Sum is their total.
+	Count int64
+	Sum   time.Duration
+}
+
+// Bucket is a bucket of Histogram.
+type Bucket struct {
+	// Le is the upper bound of the bucket.
+	Le    time.Duration
+	Count int64
+}
+
+// histogram counts delays by buckets, it is expvar.Var formatted
+// as JSON of cumulative buckets in seconds, e.g.
+// {"0.001": 0, "0.005": 2, ..., "+Inf": 3, "count": 3, "sum": 0.012}
+type histogram struct {
+	bounds []time.Duration
+	// counts are counts of the buckets, the last one of delays
+	// longer than every bound
+	counts []int64
+	count  int64
+	sum    int64
+}
+
+func newHistogram(bounds []time.Duration) *histogram {
+	return &histogram{
+		bounds: append([]time.Duration(nil), bounds...),
+		counts: make([]int64, len(bounds)+1),
+	}
+}
+
+// observe adds delay d to the histogram
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.bounds) && d > h.bounds[i] {
+		i++
+	}
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+func (h *histogram) snapshot() Histogram {
+	snapshot := Histogram{
+		Buckets: make([]Bucket, len(h.bounds)),
+		Count:   atomic.LoadInt64(&h.count),
+		Sum:     time.Duration(atomic.LoadInt64(&h.sum)),
+	}
+	var cumulative int64
+	for i, bound := range h.bounds {
+		cumulative += atomic.LoadInt64(&h.counts[i])
+		snapshot.Buckets[i] = Bucket{Le: bound, Count: cumulative}
+	}
+	return snapshot
+}
+
+// String implements expvar.Var.
+func (h *histogram) String() string {
+	snapshot := h.snapshot()
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for _, bucket := range snapshot.Buckets {
+		fmt.Fprintf(&b, "%q: %d, ", fmt.Sprint(bucket.Le.Seconds()), bucket.Count)
+	}
+	fmt.Fprintf(&b, `"+Inf": %d, "count": %d, "sum": %g}`, snapshot.Count, snapshot.Count, snapshot.Sum.Seconds())
+	return b.String()
+}
@@ -0,0 +1,50 @@
+package retrymongo_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/osvim/retry"
+	"github.com/osvim/retry/retrymongo"
+)
+
+// labeledError mimics mongo.CommandError
+type labeledError struct {
+	labels []string
+}
+
+func (e labeledError) Error() string { return fmt.Sprintf("command failed %v", e.labels) }
+
+func (e labeledError) HasErrorLabel(label string) bool {
+	for _, l := range e.labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+func ExampleTransaction() {
+	var bodies, commits int
+
+	body := func(ctx context.Context) error {
+		bodies++
+		if bodies < 2 {
+			return labeledError{labels: []string{retrymongo.TransientTransactionError}}
+		}
+		return nil
+	}
+
+	commit := func(ctx context.Context) error {
+		commits++
+		if commits < 2 {
+			return labeledError{labels: []string{retrymongo.UnknownTransactionCommitResult}}
+		}
+		return nil
+	}
+
+	err := retrymongo.Transaction(context.TODO(), retry.Attempts(3), body, commit)
+
+	fmt.Println(err, bodies, commits)
+	// Output: <nil> 2 2
+}
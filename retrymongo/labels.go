@@ -0,0 +1,60 @@
+// Package retrymongo retries MongoDB transactions on transient errors.
+// It does not depend on the driver: errors are classified by
+// HasErrorLabel(string) bool method of mongo.ServerError.
+package retrymongo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/osvim/retry"
+)
+
+// Error labels of MongoDB server errors.
+const (
+	TransientTransactionError      = "TransientTransactionError"
+	UnknownTransactionCommitResult = "UnknownTransactionCommitResult"
+)
+
+// TransientTransaction reports whether the whole transaction failed with err
+// can be retried: err has TransientTransactionError label.
+func TransientTransaction(err error) bool {
+	return hasLabel(err, TransientTransactionError)
+}
+
+// UnknownCommitResult reports whether the commit failed with err
+// can be retried: err has UnknownTransactionCommitResult label.
+func UnknownCommitResult(err error) bool {
+	return hasLabel(err, UnknownTransactionCommitResult)
+}
+
+// Retryable reports whether err has TransientTransactionError
+// or UnknownTransactionCommitResult label.
+func Retryable(err error) bool {
+	return TransientTransaction(err) || UnknownCommitResult(err)
+}
+
+// Transaction runs body and commit of a transaction with the policy
+// as recommended for MongoDB drivers: the whole transaction is retried
+// on TransientTransactionError, the commit alone is retried on
+// UnknownTransactionCommitResult. Body is expected to start the transaction
+// and abort it on failure, e.g. with mongo.Session.
+func Transaction(ctx context.Context, policy retry.Retry, body, commit func(ctx context.Context) error) error {
+	return policy.DoContext(ctx, retry.Ctx(func(ctx context.Context) error {
+		if err := body(ctx); err != nil {
+			return err
+		}
+
+		err := policy.DoContext(ctx, retry.Ctx(commit, UnknownCommitResult))
+		if UnknownCommitResult(err) {
+			// commit retries are exhausted, the outcome is still unknown
+			return retry.Abort(err)
+		}
+		return err
+	}, TransientTransaction))
+}
+
+func hasLabel(err error, label string) bool {
+	var labeled interface{ HasErrorLabel(string) bool }
+	return errors.As(err, &labeled) && labeled.HasErrorLabel(label)
+}
@@ -0,0 +1,69 @@
+package retrypgx_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/osvim/retry"
+	"github.com/osvim/retry/retrypgx"
+)
+
+// pgError mimics pgconn.PgError
+type pgError struct {
+	code string
+}
+
+func (e *pgError) Error() string    { return "ERROR: SQLSTATE " + e.code }
+func (e *pgError) SQLState() string { return e.code }
+
+// tx mimics pgx.Tx
+type tx struct {
+	commit error
+}
+
+func (t tx) Commit(ctx context.Context) error   { return t.commit }
+func (t tx) Rollback(ctx context.Context) error { return nil }
+
+func ExampleBeginTxFunc() {
+	var begins int
+
+	// begin would be pool.Begin
+	begin := func(ctx context.Context) (tx, error) {
+		begins++
+		if begins < 3 {
+			return tx{commit: &pgError{code: retrypgx.SerializationFailure}}, nil
+		}
+		return tx{}, nil
+	}
+
+	err := retrypgx.BeginTxFunc(context.TODO(), retry.Attempts(5), begin, func(tx tx) error {
+		return nil
+	})
+
+	fmt.Println(err, begins)
+	// Output: <nil> 3
+}
+
+func ExampleRetryable() {
+	fmt.Println(retrypgx.Retryable(&pgError{code: "40P01"}), retrypgx.Retryable(&pgError{code: "23505"}))
+	// Output: true false
+}
+
+func ExampleRetryableCommit() {
+	var begins int
+	begin := func(ctx context.Context) (tx, error) {
+		begins++
+		// the connection is lost during commit, it may have been applied
+		return tx{commit: &pgError{code: "08006"}}, nil
+	}
+
+	err := retrypgx.BeginTxFunc(context.TODO(), retry.Attempts(5), begin, func(tx tx) error {
+		return nil
+	})
+
+	fmt.Println(err, begins)
+	fmt.Println(retrypgx.RetryableCommit(&pgError{code: retrypgx.SerializationFailure}))
+	// Output:
+	// ERROR: SQLSTATE 08006 1
+	// true
+}
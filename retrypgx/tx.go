@@ -0,0 +1,108 @@
+// Package retrypgx retries PostgreSQL transactions of pgx
+// on serialization and connection errors. It does not depend on pgx:
+// errors are classified by SQLState() and SafeToRetry() methods
+// of pgconn errors, transactions are used via Tx interface.
+package retrypgx
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/osvim/retry"
+)
+
+// SQLSTATE codes of errors retried by Retryable.
+const (
+	SerializationFailure = "40001"
+	DeadlockDetected     = "40P01"
+	// ConnectionException is the class of connection errors.
+	ConnectionException = "08"
+	AdminShutdown       = "57P01"
+	CrashShutdown       = "57P02"
+	CannotConnectNow    = "57P03"
+)
+
+// Tx is the subset of pgx.Tx finishing a transaction.
+type Tx interface {
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// BeginTxFunc begins a transaction with begin, calls fn and commits
+// the transaction, rolling it back if fn fails. The whole transaction is retried
+// with the policy on errors classified by Retryable:
+//
+//	err := retrypgx.BeginTxFunc(ctx, policy, pool.Begin, func(tx pgx.Tx) error {
+//		...
+//	})
+//
+// A commit failed with a connection or shutdown error is not retried:
+// the commit may have been applied before the connection was lost, so
+// retrying could apply a non-idempotent transaction twice. Only commits
+// rejected by the server, e.g. with a serialization failure, or never sent
+// are retried, see RetryableCommit.
+func BeginTxFunc[T Tx](ctx context.Context, policy retry.Retry, begin func(ctx context.Context) (T, error), fn func(tx T) error) error {
+	return policy.DoContext(ctx, func(ctx context.Context) (bool, error) {
+		tx, err := begin(ctx)
+		if err != nil {
+			return Retryable(err), err
+		}
+
+		if err := fn(tx); err != nil {
+			_ = tx.Rollback(ctx)
+			return Retryable(err), err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return RetryableCommit(err), err
+		}
+		return false, nil
+	})
+}
+
+// RetryableCommit reports whether a transaction failed to commit with err
+// can be retried: serialization failure or deadlock rolling the transaction
+// back, or pgconn error safe to retry, as nothing was sent to the server.
+// Unlike Retryable, connection and shutdown errors are not retried,
+// as the outcome of the commit is unknown.
+func RetryableCommit(err error) bool {
+	var safe interface{ SafeToRetry() bool }
+	if errors.As(err, &safe) && safe.SafeToRetry() {
+		return true
+	}
+
+	var pgErr interface{ SQLState() string }
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	switch pgErr.SQLState() {
+	case SerializationFailure, DeadlockDetected:
+		return true
+	default:
+		return false
+	}
+}
+
+// Retryable reports whether a transaction failed with err can be retried:
+// serialization failure, deadlock, connection or shutdown error,
+// or pgconn error safe to retry, as nothing was sent to the server.
+// Errors of commits are classified by RetryableCommit.
+func Retryable(err error) bool {
+	var safe interface{ SafeToRetry() bool }
+	if errors.As(err, &safe) && safe.SafeToRetry() {
+		return true
+	}
+
+	var pgErr interface{ SQLState() string }
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	switch code := pgErr.SQLState(); code {
+	case SerializationFailure, DeadlockDetected, AdminShutdown, CrashShutdown, CannotConnectNow:
+		return true
+	default:
+		return strings.HasPrefix(code, ConnectionException)
+	}
+}
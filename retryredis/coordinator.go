@@ -0,0 +1,63 @@
+package retryredis
+
+import (
+	"context"
+	"time"
+)
+
+// Counter is the subset of Redis commands used by Coordinator,
+// e.g. an adapter of go-redis client:
+//
+//	type counter struct{ *redis.Client }
+//
+//	func (c counter) Incr(ctx context.Context, key string) (int64, error) {
+//		return c.Client.Incr(ctx, key).Result()
+//	}
+//
+//	func (c counter) Expire(ctx context.Context, key string, ttl time.Duration) error {
+//		return c.Client.Expire(ctx, key, ttl).Err()
+//	}
+//
+//	func (c counter) Del(ctx context.Context, key string) error {
+//		return c.Client.Del(ctx, key).Err()
+//	}
+type Counter interface {
+	Incr(ctx context.Context, key string) (int64, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	Del(ctx context.Context, key string) error
+}
+
+// Coordinator is retry.Coordinator keeping the consecutive failures
+// of a dependency in a Redis counter shared by replicas.
+// The counter expires after ttl without failures, so a crashed replica
+// can't leave the backoff escalated forever.
+type Coordinator struct {
+	counter Counter
+	key     string
+	ttl     time.Duration
+}
+
+// NewCoordinator returns Coordinator of the counter at key,
+// non-positive ttl disables expiration.
+func NewCoordinator(counter Counter, key string, ttl time.Duration) *Coordinator {
+	return &Coordinator{counter: counter, key: key, ttl: ttl}
+}
+
+// Failed increments the counter and extends its expiration.
+func (c *Coordinator) Failed(ctx context.Context) (int, error) {
+	failures, err := c.counter.Incr(ctx, c.key)
+	if err != nil {
+		return 0, err
+	}
+	if c.ttl > 0 {
+		if err := c.counter.Expire(ctx, c.key, c.ttl); err != nil {
+			return 0, err
+		}
+	}
+	return int(failures), nil
+}
+
+// Succeeded deletes the counter.
+func (c *Coordinator) Succeeded(ctx context.Context) error {
+	return c.counter.Del(ctx, c.key)
+}
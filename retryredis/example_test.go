@@ -0,0 +1,76 @@
+package retryredis_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/osvim/retry"
+	"github.com/osvim/retry/retryredis"
+)
+
+// cmd mimics redis.Cmder
+type cmd struct {
+	name string
+}
+
+func ExampleProcessHook() {
+	var calls int
+
+	// next would be redis.ProcessHook passed to Hook.ProcessHook
+	next := func(ctx context.Context, c *cmd) error {
+		calls++
+		if calls < 2 {
+			return errors.New("LOADING Redis is loading the dataset in memory")
+		}
+		return nil
+	}
+
+	process := retryredis.ProcessHook(retry.Attempts(3), next)
+
+	err := process(context.TODO(), &cmd{name: "get"})
+	fmt.Println(err, calls)
+	// Output: <nil> 2
+}
+
+func ExampleRetryable() {
+	fmt.Println(retryredis.Retryable(errors.New("MOVED 3999 127.0.0.1:6381")), retryredis.Retryable(errors.New("redis: nil")))
+	// Output: true false
+}
+
+// counter mimics Redis counters
+type counter map[string]int64
+
+func (c counter) Incr(_ context.Context, key string) (int64, error) {
+	c[key]++
+	return c[key], nil
+}
+
+func (c counter) Expire(context.Context, string, time.Duration) error { return nil }
+
+func (c counter) Del(_ context.Context, key string) error {
+	delete(c, key)
+	return nil
+}
+
+func ExampleNewCoordinator() {
+	coordinator := retryredis.NewCoordinator(counter{}, "backoff:payments", time.Minute)
+	policy := retry.Attempts(3).ExponentialBackoff(time.Millisecond).Coordinator(coordinator)
+
+	unavailable := func() (bool, error) { return true, errors.New("unavailable") }
+
+	// the first replica fails twice
+	_ = policy.Do(context.TODO(), unavailable)
+
+	// the second replica continues the backoff escalated by the first one
+	var trace strings.Builder
+	_ = policy.Explain(&trace).Do(context.TODO(), unavailable)
+
+	fmt.Print(trace.String())
+	// Output:
+	// attempt 1 failed: unavailable; retryable, sleeping 8ms
+	// attempt 2 failed: unavailable; retryable, sleeping 16ms
+	// attempt 3 failed: unavailable; retryable, but no attempts left
+}
@@ -0,0 +1,89 @@
+// Package retryredis retries go-redis commands with retry policies.
+// It does not depend on go-redis: hooks are generic over the command type,
+// so they fit redis.ProcessHook and redis.ProcessPipelineHook:
+//
+//	type retryHook struct{ policy retry.Retry }
+//
+//	func (h retryHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+//
+//	func (h retryHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+//		return retryredis.ProcessHook(h.policy, next)
+//	}
+//
+//	func (h retryHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+//		return retryredis.ProcessPipelineHook(h.policy, next)
+//	}
+//
+//	client := redis.NewClient(&redis.Options{MaxRetries: -1})
+//	client.AddHook(retryHook{policy: policy})
+//
+// MaxRetries: -1 disables the built-in retries of the client.
+package retryredis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/osvim/retry"
+)
+
+// retryablePrefixes are prefixes of Redis errors worth retrying
+var retryablePrefixes = []string{
+	"MOVED ",
+	"ASK ",
+	"TRYAGAIN",
+	"LOADING",
+	"CLUSTERDOWN",
+	"MASTERDOWN",
+	"READONLY",
+}
+
+// ProcessHook returns a hook calling next with the policy,
+// errors are classified by Retryable. C is redis.Cmder.
+// Errors are returned without retry metadata, so redis.Nil
+// can be compared by ==.
+func ProcessHook[C any](policy retry.Retry, next func(ctx context.Context, cmd C) error) func(ctx context.Context, cmd C) error {
+	return func(ctx context.Context, cmd C) error {
+		return retry.Cause(policy.DoContext(ctx, retry.Ctx(func(ctx context.Context) error {
+			return next(ctx, cmd)
+		}, Retryable)))
+	}
+}
+
+// ProcessPipelineHook works same as ProcessHook for pipelines,
+// the whole pipeline is retried.
+func ProcessPipelineHook[C any](policy retry.Retry, next func(ctx context.Context, cmds []C) error) func(ctx context.Context, cmds []C) error {
+	return func(ctx context.Context, cmds []C) error {
+		return retry.Cause(policy.DoContext(ctx, retry.Ctx(func(ctx context.Context) error {
+			return next(ctx, cmds)
+		}, Retryable)))
+	}
+}
+
+// Retryable reports whether a command failed with err can be retried:
+// cluster redirections (MOVED, ASK), TRYAGAIN, LOADING, CLUSTERDOWN,
+// MASTERDOWN, READONLY replies, network timeouts and closed connections.
+func Retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	message := err.Error()
+	for _, prefix := range retryablePrefixes {
+		if strings.HasPrefix(message, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package retryremote_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/osvim/retry"
+	"github.com/osvim/retry/retryremote"
+)
+
+func ExamplePoller_Fetch() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"attempts":5,"backoff":"exponential","initial":"100ms","cap":"800ms","jitter":0.2}`)
+	}))
+	defer server.Close()
+
+	policy := retry.NewDynamicRetry(retry.None())
+	poller := retryremote.NewPoller(server.URL, policy)
+
+	for i := 0; i < 2; i++ {
+		if err := poller.Fetch(context.TODO()); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(policy.Load())
+	}
+	// Output:
+	// 5 attempts, exponential backoff 100ms..800ms, jitter 20%
+	// 5 attempts, exponential backoff 100ms..800ms, jitter 20%
+}
+
+func ExampleWithBase() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"attempts":3}`)
+	}))
+	defer server.Close()
+
+	base := retry.Attempts(5).OnExhausted(func(ctx context.Context, attempts int, lastErr error) {
+		fmt.Println("exhausted after", attempts, "attempts:", lastErr)
+	})
+	policy := retry.NewDynamicRetry(retry.None())
+	poller := retryremote.NewPoller(server.URL, policy, retryremote.WithBase(base))
+	if err := poller.Fetch(context.TODO()); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	_ = policy.Do(context.TODO(), func() (bool, error) {
+		return true, errors.New("unavailable")
+	})
+	// Output:
+	// exhausted after 3 attempts: unavailable
+}
+
+func ExamplePoller_Fetch_partial() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fields present in the policy override the base, even if zero
+		fmt.Fprint(w, `{"backoff":"linear","jitter":0}`)
+	}))
+	defer server.Close()
+
+	base := retry.Attempts(3).ExponentialJitterBackoff(100*time.Millisecond, 0.3)
+	policy := retry.NewDynamicRetry(base)
+	poller := retryremote.NewPoller(server.URL, policy)
+	if err := poller.Fetch(context.TODO()); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(policy.Load())
+	// Output: 3 attempts, linear backoff 100ms
+}
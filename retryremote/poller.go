@@ -0,0 +1,146 @@
+// Package retryremote fetches retry policies from an HTTP endpoint,
+// so retry behaviour of a fleet can be tuned without redeploys.
+package retryremote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/osvim/retry"
+)
+
+// DefaultInterval is the interval of fetching a policy by Poller
+// created without WithInterval.
+const DefaultInterval = time.Minute
+
+// maxPolicySize is the max size of a fetched policy
+const maxPolicySize = 64 << 10
+
+// Option configures Poller.
+type Option func(*Poller)
+
+// WithInterval sets the interval of fetching the policy, DefaultInterval by default
+// and for non-positive intervals.
+func WithInterval(interval time.Duration) Option {
+	return func(p *Poller) {
+		p.interval = interval
+	}
+}
+
+// WithClient sets the client fetching the policy, http.DefaultClient by default.
+func WithClient(client *http.Client) Option {
+	return func(p *Poller) {
+		p.client = client
+	}
+}
+
+// WithBase sets the policy the fetched one is merged over, see retry.Config.Merge,
+// the initial policy of the target by default. Fields the JSON can't carry,
+// e.g. RetryIf, recorders, hooks and the clock, are kept from base.
+func WithBase(base retry.Retry) Option {
+	return func(p *Poller) {
+		p.base = base
+	}
+}
+
+// WithOnError calls onError when fetching the policy fails,
+// the current policy is kept.
+func WithOnError(onError func(err error)) Option {
+	return func(p *Poller) {
+		p.onError = onError
+	}
+}
+
+// Poller periodically fetches a policy in JSON representation of
+// retry.Retry, e.g. {"attempts":5,"backoff":"exponential","initial":"100ms","cap":"800ms"},
+// and stores it to retry.DynamicRetry merged over the base policy. The last fetched policy is cached:
+// responses are validated with ETag, and failed fetches keep the policy.
+type Poller struct {
+	url      string
+	target   *retry.DynamicRetry
+	base     retry.Retry
+	client   *http.Client
+	interval time.Duration
+	onError  func(err error)
+	etag     string
+}
+
+// NewPoller returns Poller of the policy at url feeding target.
+func NewPoller(url string, target *retry.DynamicRetry, opts ...Option) *Poller {
+	p := &Poller{url: url, target: target, base: target.Load(), client: http.DefaultClient, interval: DefaultInterval}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.interval <= 0 {
+		p.interval = DefaultInterval
+	}
+	return p
+}
+
+// Run fetches the policy every interval until ctx is done,
+// it returns the error of ctx. Run is not safe for concurrent use.
+func (p *Poller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Fetch(ctx); err != nil && p.onError != nil && ctx.Err() == nil {
+			p.onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Fetch fetches the policy once and stores it, unless it is not modified
+// since the previous fetch. Fetch is not safe for concurrent use.
+func (p *Poller) Fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return err
+	}
+	if p.etag != "" {
+		req.Header.Set("If-None-Match", p.etag)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil
+	default:
+		return fmt.Errorf("retryremote: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPolicySize))
+	if err != nil {
+		return err
+	}
+
+	var cfg retry.Config
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return fmt.Errorf("retryremote: %w", err)
+	}
+	// the fetched policy may be partial, so the merged one is validated
+	policy, err := retry.NewStrict(p.base.Config().Merge(cfg))
+	if err != nil {
+		return fmt.Errorf("retryremote: %w", err)
+	}
+	p.target.Store(policy)
+
+	p.etag = resp.Header.Get("ETag")
+	return nil
+}
@@ -0,0 +1,72 @@
+package retrytest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/osvim/retry"
+)
+
+// Clock is retry.Clock of virtual time advanced by its timers: a timer
+// fires immediately moving the time forward by its duration, so backoff
+// sleeps take no real time. Clock is safe for concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns Clock starting at start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the virtual time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since returns the virtual time elapsed since t.
+func (c *Clock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Advance moves the virtual time forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// NewTimer advances the virtual time by d and returns a fired Timer.
+func (c *Clock) NewTimer(d time.Duration) retry.Timer {
+	if d > 0 {
+		c.Advance(d)
+	}
+	fired := make(chan time.Time, 1)
+	fired <- c.Now()
+	return timer(fired)
+}
+
+// timer is a fired retry.Timer of Clock
+type timer chan time.Time
+
+func (t timer) C() <-chan time.Time { return t }
+
+func (t timer) Stop() bool { return false }
+
+// Do runs Do of the policy with clock advancing whenever the policy
+// sleeps, so a schedule of minutes is exercised in microseconds; a nil
+// clock starts at time.Now. Pass the same clock as CountingFunc.Clock to
+// record the calls in the virtual time. Do returns the virtual time
+// elapsed by the call. Deadlines of ctx are measured by the real time.
+func Do(ctx context.Context, clock *Clock, policy retry.Retry, call retry.Func) (time.Duration, error) {
+	if clock == nil {
+		clock = NewClock(time.Now())
+	}
+	started := clock.Now()
+	err := policy.Clock(clock).Do(ctx, call)
+	return clock.Since(started), err
+}
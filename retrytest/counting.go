@@ -0,0 +1,84 @@
+// Package retrytest provides helpers for testing code retrying with
+// retry policies.
+package retrytest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/osvim/retry"
+)
+
+// T is the subset of testing.TB used by assertions.
+type T interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Call is a recorded call of CountingFunc.
+type Call struct {
+	// At is the time of the call.
+	At time.Time
+	// Err is the error returned by the call.
+	Err error
+}
+
+// CountingFunc wraps retry.Func recording its calls, see Func.
+// CountingFunc is safe for concurrent use.
+type CountingFunc struct {
+	// Clock times the calls, retry.SystemClock if nil.
+	Clock retry.Clock
+
+	call  retry.Func
+	mu    sync.Mutex
+	calls []Call
+}
+
+// NewCountingFunc returns CountingFunc wrapping call.
+func NewCountingFunc(call retry.Func) *CountingFunc {
+	return &CountingFunc{call: call}
+}
+
+// Func calls the wrapped retry.Func and records the call,
+// it is passed to Do as c.Func.
+func (c *CountingFunc) Func() (bool, error) {
+	clock := c.Clock
+	if clock == nil {
+		clock = retry.SystemClock
+	}
+	at := clock.Now()
+
+	repeat, err := c.call()
+
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{At: at, Err: err})
+	c.mu.Unlock()
+	return repeat, err
+}
+
+// Calls returns the recorded calls in the order they were made.
+func (c *CountingFunc) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// AssertCalls reports an error unless Func was called n times.
+func (c *CountingFunc) AssertCalls(t T, n int) {
+	t.Helper()
+	if calls := len(c.Calls()); calls != n {
+		t.Errorf("retrytest: %d calls, want %d", calls, n)
+	}
+}
+
+// AssertBackoffAtLeast reports an error if any two consecutive calls
+// were made less than d apart.
+func (c *CountingFunc) AssertBackoffAtLeast(t T, d time.Duration) {
+	t.Helper()
+	calls := c.Calls()
+	for i := 1; i < len(calls); i++ {
+		if gap := calls[i].At.Sub(calls[i-1].At); gap < d {
+			t.Errorf("retrytest: backoff %s before call %d, want at least %s", gap, i+1, d)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package retrytest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/osvim/retry"
+	"github.com/osvim/retry/retrytest"
+)
+
+// printT prints errors of assertions, *testing.T is passed in tests
+type printT struct{}
+
+func (printT) Helper() {}
+
+func (printT) Errorf(format string, args ...interface{}) {
+	fmt.Printf(format+"\n", args...)
+}
+
+func ExampleCountingFunc() {
+	counting := retrytest.NewCountingFunc(func() (bool, error) {
+		return true, errors.New("unavailable")
+	})
+
+	_ = retry.Attempts(3).JitterBackoff(10*time.Millisecond, 0).Do(context.Background(), counting.Func)
+
+	t := printT{}
+	counting.AssertCalls(t, 3)
+	counting.AssertBackoffAtLeast(t, 10*time.Millisecond)
+	counting.AssertCalls(t, 2)
+	// Output: retrytest: 3 calls, want 2
+}
+
+func ExampleDo() {
+	policy := retry.Attempts(6).ExponentialJitterBackoff(20*time.Second, 0)
+
+	elapsed, err := retrytest.Do(context.Background(), nil, policy, func() (bool, error) {
+		return true, errors.New("unavailable")
+	})
+
+	fmt.Println(elapsed, err)
+	// Output: 10m20s no attempts left: unavailable
+}
+
+func ExampleDo_counting() {
+	clock := retrytest.NewClock(time.Now())
+	counting := retrytest.NewCountingFunc(func() (bool, error) {
+		return true, errors.New("unavailable")
+	})
+	counting.Clock = clock
+
+	policy := retry.Attempts(4).JitterBackoff(time.Minute, 0)
+	elapsed, _ := retrytest.Do(context.Background(), clock, policy, counting.Func)
+
+	fmt.Println(elapsed)
+	counting.AssertCalls(printT{}, 4)
+	counting.AssertBackoffAtLeast(printT{}, time.Minute)
+	// Output: 3m0s
+}
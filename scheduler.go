@@ -0,0 +1,370 @@
+package retry
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrSchedulerClosed is returned by Scheduler.Submit after Shutdown,
+// and passed to the handler of WithOnJobDone for abandoned jobs.
+var ErrSchedulerClosed = errors.New("retry: scheduler closed")
+
+// SchedulerOption configures Scheduler.
+type SchedulerOption func(*Scheduler)
+
+// WithFastFail makes Scheduler.Shutdown stop jobs sleeping in backoff
+// instead of waiting for their retries, attempts in flight are finished.
+func WithFastFail() SchedulerOption {
+	return func(s *Scheduler) {
+		s.fastFail = true
+	}
+}
+
+// WithOnJobDone sets the handler of the final error of every job,
+// err is nil if the job succeeded.
+func WithOnJobDone(onDone func(id string, err error)) SchedulerOption {
+	return func(s *Scheduler) {
+		s.onDone = onDone
+	}
+}
+
+// WithWorkers sets the number of attempts made by Scheduler
+// concurrently, 1 by default.
+func WithWorkers(n int) SchedulerOption {
+	return func(s *Scheduler) {
+		s.workers = n
+	}
+}
+
+// Job is a job of Scheduler.
+type Job struct {
+	// ID identifies the job for WithOnJobDone and Scheduler.Shutdown.
+	ID string
+	// Run makes an attempt, its errors are classified by WithRetryIf
+	// of the policy, every error is retried by default. The context
+	// of the attempt is limited by the attempt timeout of the policy.
+	Run func(ctx context.Context) error
+	// Policy retries the job, the policy of Scheduler if nil.
+	Policy *Retry
+	// At is the time of the first attempt, the job is due immediately if zero.
+	At time.Time
+	// MaxAge limits the time since submission the job is retried for,
+	// unlimited if zero.
+	MaxAge time.Duration
+}
+
+// job is a job queued by Scheduler
+type job struct {
+	Job
+	policy   Retry
+	seq      int
+	next     time.Time
+	deadline time.Time
+	attempts int
+	state    backoffState
+}
+
+// jobQueue is a min-heap of jobs by the time of the next attempt
+type jobQueue []*job
+
+func (q jobQueue) Len() int { return len(q) }
+
+func (q jobQueue) Less(i, j int) bool {
+	if q[i].next.Equal(q[j].next) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].next.Before(q[j].next)
+}
+
+func (q jobQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *jobQueue) Push(x interface{}) { *q = append(*q, x.(*job)) }
+
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	j := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return j
+}
+
+// Scheduler is a delay queue of jobs retried in background, e.g. deliveries
+// of webhooks accepted by a request handler. Workers don't sleep in backoff:
+// a failed job is queued until its next attempt, so a few workers serve
+// many jobs. Shutdown stops the workers. Scheduler is safe for concurrent use.
+type Scheduler struct {
+	policy   Retry
+	fastFail bool
+	onDone   func(id string, err error)
+	workers  int
+
+	mu     sync.Mutex
+	queue  jobQueue
+	seq    int
+	closed bool
+	// stopping drops queued jobs after Shutdown with WithFastFail
+	stopping bool
+	// running are ids of jobs with attempts in flight by seq
+	running   map[int]string
+	abandoned []string
+	// changed is closed and replaced when the queue changes
+	changed chan struct{}
+	wg      sync.WaitGroup
+	// stop is the context of attempts, cancelled when the context
+	// of Shutdown is done
+	stop   context.Context
+	cancel context.CancelFunc
+}
+
+// NewScheduler returns Scheduler retrying jobs with the policy,
+// unless a job has its own one. The clock of the policy times the jobs.
+func NewScheduler(policy Retry, opts ...SchedulerOption) *Scheduler {
+	s := &Scheduler{
+		policy:  policy,
+		workers: 1,
+		running: make(map[int]string),
+		changed: make(chan struct{}),
+	}
+	s.stop, s.cancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.workers < 1 {
+		s.workers = 1
+	}
+
+	s.wg.Add(s.workers)
+	for i := 0; i < s.workers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+// Submit schedules the job identified by id for immediate execution
+// with the policy of Scheduler. The context of the job is cancelled
+// only when the context of Shutdown is done, so attempts in flight
+// are finished by a graceful Shutdown.
+func (s *Scheduler) Submit(id string, run func(ctx context.Context) error) error {
+	return s.Schedule(Job{ID: id, Run: run})
+}
+
+// Schedule queues the job until its first attempt.
+func (s *Scheduler) Schedule(j Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSchedulerClosed
+	}
+
+	queued := &job{Job: j, policy: s.policy, seq: s.seq, next: j.At}
+	s.seq++
+	if j.Policy != nil {
+		queued.policy = *j.Policy
+	}
+	now := s.policy.now()
+	if queued.next.IsZero() {
+		queued.next = now
+	}
+	if j.MaxAge > 0 {
+		queued.deadline = now.Add(j.MaxAge)
+	}
+	heap.Push(&s.queue, queued)
+	s.notify()
+	return nil
+}
+
+// Pending returns the number of queued jobs, excluding attempts in flight.
+func (s *Scheduler) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// NextWake returns the time of the earliest queued attempt,
+// ok is false if the queue is empty.
+func (s *Scheduler) NextWake() (next time.Time, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return time.Time{}, false
+	}
+	return s.queue[0].next, true
+}
+
+// notify wakes up workers waiting for changes of the queue,
+// s.mu must be held
+func (s *Scheduler) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// work makes attempts of due jobs until Scheduler is drained
+func (s *Scheduler) work() {
+	defer s.wg.Done()
+	for {
+		j := s.next()
+		if j == nil {
+			return
+		}
+		s.attempt(j)
+	}
+}
+
+// next waits for a due job, nil means Scheduler is drained
+func (s *Scheduler) next() *job {
+	for {
+		s.mu.Lock()
+		if s.closed && len(s.queue) == 0 && len(s.running) == 0 {
+			s.mu.Unlock()
+			return nil
+		}
+
+		var timer Timer
+		if len(s.queue) > 0 {
+			delay := s.policy.until(s.queue[0].next)
+			if delay <= 0 {
+				j := heap.Pop(&s.queue).(*job)
+				s.running[j.seq] = j.ID
+				s.mu.Unlock()
+				return j
+			}
+			timer = s.policy.timer(delay)
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if timer == nil {
+			<-changed
+			continue
+		}
+		select {
+		case <-changed:
+		case <-timer.C():
+		}
+		timer.Stop()
+	}
+}
+
+// attempt runs the job once and queues it again if it should be retried
+func (s *Scheduler) attempt(j *job) {
+	// the context of the attempt is limited by the policy as by DoContext
+	scope := attemptScope{attempt: j.attempts, left: j.policy.attempts - j.attempts}
+	retry, err := lockedCall(j.policy.locker, func() (bool, error) {
+		return j.policy.call(s.stop, scope, Ctx(j.Run, j.policy.retryIf))
+	})
+	j.attempts++
+
+	var final error
+	retry = err != nil && retry
+	switch {
+	case err == nil:
+	case !retry:
+		final = unwrapAbort(err)
+	case j.attempts >= j.policy.attempts:
+		final = j.policy.exhaustedError(j.attempts, err)
+	default:
+		delay, _ := j.policy.delayAfter(&j.state, err)
+		j.next = s.policy.now().Add(delay)
+		if !j.deadline.IsZero() && j.next.After(j.deadline) {
+			final = j.policy.exhaustedError(j.attempts, err)
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.running, j.seq)
+	if s.stop.Err() != nil {
+		// the job is abandoned by Shutdown
+		s.notify()
+		s.mu.Unlock()
+		return
+	}
+	requeue := retry && final == nil
+	if requeue && s.stopping {
+		s.abandoned = append(s.abandoned, j.ID)
+		requeue, final = false, ErrSchedulerClosed
+	}
+	if requeue {
+		heap.Push(&s.queue, j)
+	}
+	s.notify()
+	s.mu.Unlock()
+
+	if !requeue && s.onDone != nil {
+		s.onDone(j.ID, final)
+	}
+}
+
+// Shutdown stops accepting jobs and waits for queued jobs to finish,
+// retries included, until ctx is done. With WithFastFail queued jobs
+// are dropped instead, once attempts in flight are finished. Shutdown
+// returns ids of abandoned jobs: the dropped ones and ones still queued
+// or running when ctx is done, in the latter case the error of ctx
+// is returned as well. When ctx is done, the queued jobs are dropped and
+// the contexts of running ones are cancelled, abandoned jobs are neither
+// retried nor passed to the handler of WithOnJobDone.
+func (s *Scheduler) Shutdown(ctx context.Context) (abandoned []string, err error) {
+	var dropped []*job
+	s.mu.Lock()
+	s.closed = true
+	if s.fastFail {
+		s.stopping = true
+		dropped = s.queue
+		s.queue = nil
+		for _, j := range sortedJobs(dropped) {
+			s.abandoned = append(s.abandoned, j.ID)
+		}
+	}
+	s.notify()
+	s.mu.Unlock()
+
+	if s.onDone != nil {
+		for _, j := range sortedJobs(dropped) {
+			s.onDone(j.ID, ErrSchedulerClosed)
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// running jobs see the cancelled context under the lock,
+	// so they are either reported below or done already
+	s.cancel()
+	s.stopping = true
+	s.notify()
+	abandoned = append(abandoned, s.abandoned...)
+	seqs := make([]int, 0, len(s.running))
+	for seq := range s.running {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	for _, seq := range seqs {
+		abandoned = append(abandoned, s.running[seq])
+	}
+	for _, j := range sortedJobs(s.queue) {
+		abandoned = append(abandoned, j.ID)
+	}
+	s.queue = nil
+	return abandoned, err
+}
+
+// sortedJobs returns jobs sorted in the order of submission
+func sortedJobs(jobs []*job) []*job {
+	sorted := append([]*job(nil), jobs...)
+	sort.Slice(sorted, func(i, k int) bool { return sorted[i].seq < sorted[k].seq })
+	return sorted
+}
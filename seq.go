@@ -0,0 +1,24 @@
+//go:build go1.23
+
+package retry
+
+import (
+	"iter"
+	"time"
+)
+
+// Seq returns the delays after the failed attempts of maxAttempts,
+// the last attempt is not followed by a delay:
+//
+//	for delay := range b.Seq(5) {
+//		log.Println("next delay", delay)
+//	}
+func (b Backoff) Seq(maxAttempts int) iter.Seq[time.Duration] {
+	return func(yield func(time.Duration) bool) {
+		for attempt := 0; attempt < maxAttempts-1; attempt++ {
+			if !yield(b(attempt)) {
+				return
+			}
+		}
+	}
+}
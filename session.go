@@ -0,0 +1,116 @@
+package retry
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// backoffState is the state of backoff of a Do call or Session.
+type backoffState struct {
+	// mu guards the state shared by Session, nil otherwise
+	mu *sync.Mutex
+	// failures is the number of failed attempts
+	failures int
+	// prev is the previous delay of decorrelated backoff
+	prev time.Duration
+	// random is the state of random numbers seeded by Retry.Deterministic
+	random uint64
+	seeded bool
+}
+
+func (s *backoffState) lock() {
+	if s.mu != nil {
+		s.mu.Lock()
+	}
+}
+
+func (s *backoffState) unlock() {
+	if s.mu != nil {
+		s.mu.Unlock()
+	}
+}
+
+// reset forgets failures
+func (s *backoffState) reset() {
+	s.lock()
+	s.failures, s.prev = 0, 0
+	s.unlock()
+}
+
+// Session shares the backoff state across Do calls, so backoff keeps
+// escalating from one call to another until a call succeeds,
+// e.g. in a reconnect loop. Do calls of Retry have isolated state.
+// Session is safe for concurrent use.
+type Session struct {
+	policy Retry
+	state  backoffState
+}
+
+// NewSession returns Session of the policy with the initial backoff state.
+func (r Retry) NewSession() *Session {
+	return &Session{policy: r, state: backoffState{mu: new(sync.Mutex)}}
+}
+
+// Do works same as Retry.Do, but continues the backoff of the previous calls.
+// The state is reset when Do succeeds.
+func (s *Session) Do(ctx context.Context, call Func) error {
+	err := s.policy.do(ctx, call, &s.state, nil, nil)
+	if err == nil {
+		s.state.reset()
+	}
+	return err
+}
+
+// Reset resets the backoff to the initial state.
+func (s *Session) Reset() {
+	s.state.reset()
+}
+
+// sessionStateVersion is the version of the binary format of Session state
+const sessionStateVersion = 1
+
+// errSessionState is the error of UnmarshalBinary for malformed data
+var errSessionState = errors.New("retry: malformed session state")
+
+// MarshalBinary encodes the backoff state of Session, so a daemon
+// restarting in the middle of an outage can resume the escalated backoff
+// by UnmarshalBinary instead of hammering the dependency again.
+func (s *Session) MarshalBinary() ([]byte, error) {
+	s.state.lock()
+	failures, prev := s.state.failures, s.state.prev
+	s.state.unlock()
+
+	data := make([]byte, 1+2*binary.MaxVarintLen64)
+	data[0] = sessionStateVersion
+	n := 1 + binary.PutUvarint(data[1:], uint64(failures))
+	n += binary.PutVarint(data[n:], int64(prev))
+	return data[:n], nil
+}
+
+// UnmarshalBinary restores the backoff state encoded by MarshalBinary,
+// the policy of Session is kept.
+func (s *Session) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != sessionStateVersion {
+		return errSessionState
+	}
+	data = data[1:]
+
+	failures, n := binary.Uvarint(data)
+	if n <= 0 || failures > uint64(Unlimited) {
+		return errSessionState
+	}
+	data = data[n:]
+
+	prev, n := binary.Varint(data)
+	if n <= 0 || n != len(data) || prev < 0 {
+		return errSessionState
+	}
+
+	s.state.lock()
+	s.state.failures, s.state.prev = int(failures), time.Duration(prev)
+	s.state.unlock()
+	return nil
+}
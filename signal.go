@@ -0,0 +1,27 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"os/signal"
+)
+
+// ErrStopped is returned by Do stopped by a signal of WithStopSignals.
+var ErrStopped = errors.New("retry: stopped by signal")
+
+// notifyStop returns ctx cancelled on the first of the stop signals of Retry
+func (r *Retry) notifyStop(ctx context.Context) (context.Context, context.CancelFunc) {
+	if len(r.stopSignals) == 0 {
+		return ctx, func() {}
+	}
+	return signal.NotifyContext(ctx, r.stopSignals...)
+}
+
+// stopped replaces the error of ctx cancelled by a stop signal with ErrStopped,
+// parent is the context passed to Do
+func (r *Retry) stopped(parent context.Context, err error) error {
+	if len(r.stopSignals) > 0 && errors.Is(err, context.Canceled) && parent.Err() == nil {
+		return ErrStopped
+	}
+	return err
+}
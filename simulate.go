@@ -0,0 +1,140 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// simulationRuns is the number of Do calls simulated by Simulate.
+const simulationRuns = 10000
+
+// simulationAttempts is the max number of attempts of a Do call
+// simulated by Simulate.
+const simulationAttempts = 1000
+
+// FailureModel simulates a Func call: returns the latency of the call
+// and whether it failed with a temporary error.
+// Attempt is zero-based, rnd is the source of randomness of the simulation.
+type FailureModel func(attempt int, rnd *rand.Rand) (latency time.Duration, failed bool)
+
+// FailureRate returns FailureModel of calls failing with probability p
+// after latency.
+func FailureRate(p float64, latency time.Duration) FailureModel {
+	return func(_ int, rnd *rand.Rand) (time.Duration, bool) {
+		return latency, rnd.Float64() < p
+	}
+}
+
+// Simulation is the result of Simulate.
+type Simulation struct {
+	// Runs is the number of simulated Do calls.
+	Runs int
+	// Succeeded is the fraction of Do calls returned successfully.
+	Succeeded float64
+	// Latency is the total time of Do calls, including backoff.
+	Latency struct {
+		P50, P95, Worst time.Duration
+	}
+	// Attempts is the number of Func calls per Do call.
+	Attempts struct {
+		P50, P95, Worst int
+	}
+}
+
+// ErrorModel simulates a Func call same as FailureModel, returning
+// the error of a failed call, so the failure is routed by Switch
+// to the policy of its class.
+type ErrorModel func(attempt int, rnd *rand.Rand) (latency time.Duration, err error)
+
+// errSimulated is the error of calls failed by FailureModel
+var errSimulated = errors.New("retry: simulated failure")
+
+// Simulate estimates latency and attempts of Do calls with the policy
+// using Monte-Carlo method, without real sleeping,
+// so the policy can be checked to fit a request deadline.
+// Policies chained by Then and routed by Switch are simulated as by Do.
+// Do calls are cut after 1000 attempts, e.g. of Forever policies,
+// and count as failed.
+func Simulate(policy Retry, model FailureModel) Simulation {
+	return SimulateErrors(policy, func(attempt int, rnd *rand.Rand) (time.Duration, error) {
+		latency, failed := model(attempt, rnd)
+		if failed {
+			return latency, errSimulated
+		}
+		return latency, nil
+	})
+}
+
+// SimulateErrors works same as Simulate with the errors of calls
+// returned by model.
+func SimulateErrors(policy Retry, model ErrorModel) Simulation {
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	// the decisions of simulated calls are not traced
+	policy.explain = nil
+
+	lim := policy.limits(context.Background())
+	bound := lim.bound
+	if bound > simulationAttempts {
+		bound = simulationAttempts
+	}
+
+	var (
+		sim       = Simulation{Runs: simulationRuns}
+		latencies = make([]time.Duration, simulationRuns)
+		attempts  = make([]int, simulationRuns)
+		succeeded int
+	)
+	for run := range latencies {
+		var (
+			elapsed time.Duration
+			state   backoffState
+			routes  routing
+			chain   stages
+		)
+		for attempt := 0; attempt < bound; attempt++ {
+			latency, err := model(attempt, rnd)
+			elapsed += latency
+			attempts[run]++
+
+			if err == nil {
+				succeeded++
+				break
+			}
+			if policy.thinned() && rnd.Float64() >= policy.probability {
+				break
+			}
+
+			stage, stageState, exhausted := policy, &state, attempt == lim.attempts-1
+			switch {
+			case lim.routed:
+				var failures int
+				stage, stageState, failures = policy.route(&routes, err, &state)
+				exhausted = failures >= stage.attempts
+			case lim.chained:
+				stage, stageState, exhausted = policy.stage(&chain, attempt, &state)
+			}
+			if exhausted || attempt == bound-1 {
+				break
+			}
+			delay, _ := stage.delayAfter(stageState, err)
+			elapsed += delay
+		}
+		latencies[run] = elapsed
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	sort.Ints(attempts)
+
+	sim.Succeeded = float64(succeeded) / simulationRuns
+	sim.Latency.P50, sim.Latency.P95, sim.Latency.Worst = latencies[percentile(50)], latencies[percentile(95)], latencies[simulationRuns-1]
+	sim.Attempts.P50, sim.Attempts.P95, sim.Attempts.Worst = attempts[percentile(50)], attempts[percentile(95)], attempts[simulationRuns-1]
+	return sim
+}
+
+// percentile returns the index of percentile p of sorted simulation runs
+func percentile(p int) int {
+	return simulationRuns*p/100 - 1
+}
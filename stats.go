@@ -0,0 +1,41 @@
+package retry
+
+import (
+	"context"
+	"time"
+)
+
+// Stats describes a completed Do call, see Retry.DoWithStats.
+type Stats struct {
+	// Attempts is the number of Func calls.
+	Attempts int
+	// Elapsed is the total time of the Do call, including backoff.
+	Elapsed time.Duration
+	// Slept is the time slept in backoff, so Elapsed minus Slept
+	// is the time spent in Func calls, e.g. of a slow dependency.
+	Slept time.Duration
+	// History describes the Func calls in order.
+	History []AttemptStats
+
+	// history enables History, which is collected by DoWithStats only
+	history bool
+	// failed is the time the first attempt failed, see ElapsedFromError
+	failed time.Time
+}
+
+// AttemptStats describes a Func call of a Do call, e.g. to match
+// the attempts with the timeline of an outage in a postmortem.
+type AttemptStats struct {
+	// Start and End are the times of the call by the clock of Retry.
+	Start, End time.Time
+	// Err is the error returned by the call, nil if it succeeded.
+	Err error
+}
+
+// DoWithStats works same as Retry.Do, and describes the call by Stats.
+func (r Retry) DoWithStats(ctx context.Context, call Func) (Stats, error) {
+	var state backoffState
+	stats := Stats{history: true}
+	err := r.do(ctx, call, &state, &stats, nil)
+	return stats, err
+}
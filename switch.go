@@ -0,0 +1,55 @@
+package retry
+
+// Switch returns fallback routing retryable failures to policies by
+// the class of the error returned by classOf, e.g. throttling and
+// connection errors with different schedules at one call site.
+// The attempts and the backoff after a failure are taken from the policy
+// of its class, counting only the failures of the class; failures of
+// classes without policy follow fallback. Other settings, e.g. hooks and
+// timeouts, are taken from fallback. The classifier is separate from
+// the one of Retry.AttemptsByClass. Switch panics if classOf is nil.
+func Switch(classOf ClassOf, policies map[Class]Retry, fallback Retry) Retry {
+	if classOf == nil {
+		panic("retry: Switch with nil classOf")
+	}
+	fallback.switchClassOf, fallback.policies = classOf, policies
+	return fallback
+}
+
+// routing is the state of routes of a Do call of Switch
+type routing struct {
+	// fallback is the number of failures routed to the fallback policy
+	fallback int
+	routes   map[Class]*route
+}
+
+// route is the state of a route of a Do call
+type route struct {
+	failures int
+	state    backoffState
+}
+
+// route returns the policy of a retryable failure, its backoff state and
+// the number of failures routed to the policy, including err
+func (r Retry) route(routing *routing, err error, state *backoffState) (Retry, *backoffState, int) {
+	class := r.switchClassOf(err)
+	policy, ok := r.policies[class]
+	if !ok {
+		routing.fallback++
+		return r, state, routing.fallback
+	}
+
+	// decisions are traced by the writer of the switch
+	policy.explain = r.explain
+
+	if routing.routes == nil {
+		routing.routes = make(map[Class]*route, len(r.policies))
+	}
+	rt := routing.routes[class]
+	if rt == nil {
+		rt = new(route)
+		routing.routes[class] = rt
+	}
+	rt.failures++
+	return policy, &rt.state, rt.failures
+}
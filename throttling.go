@@ -0,0 +1,89 @@
+package retry
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// ThrottlingCodes are error codes of cloud SDKs signalling throttling,
+// e.g. by AWS (S3, DynamoDB, EC2, ...) and Google Cloud (GCS, ...).
+var ThrottlingCodes = []string{
+	"Throttling",
+	"ThrottlingException",
+	"ThrottledException",
+	"RequestThrottled",
+	"RequestThrottledException",
+	"TooManyRequestsException",
+	"ProvisionedThroughputExceededException",
+	"TransactionInProgressException",
+	"RequestLimitExceeded",
+	"BandwidthLimitExceeded",
+	"LimitExceededException",
+	"PriorRequestNotComplete",
+	"EC2ThrottledException",
+	"SlowDown",
+	"rateLimitExceeded",
+	"userRateLimitExceeded",
+	"RESOURCE_EXHAUSTED",
+}
+
+// OnThrottling returns Classifier retrying errors with one of ThrottlingCodes
+// or HTTP status 429 Too Many Requests, see OnErrorCode.
+func OnThrottling() Classifier {
+	codes := OnErrorCode(ThrottlingCodes...)
+	return func(err error) bool {
+		var status interface{ HTTPStatusCode() int }
+		if errors.As(err, &status) && status.HTTPStatusCode() == http.StatusTooManyRequests {
+			return true
+		}
+		return codes(err)
+	}
+}
+
+// OnErrorCode returns Classifier retrying errors with one of codes,
+// compared exactly, as the codes are case-sensitive. The code is taken from
+// the chain of err by ErrorCode() string method (AWS SDK v2) or Code() string
+// method (AWS SDK v1); errors without the methods are matched if their message
+// contains the code as a whole word (Google Cloud SDK reasons), so neither
+// "throttling" nor "NoThrottling" match "Throttling".
+func OnErrorCode(codes ...string) Classifier {
+	return func(err error) bool {
+		if code, ok := errorCode(err); ok {
+			return contains(codes, code)
+		}
+
+		words := strings.FieldsFunc(err.Error(), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+		})
+		for _, word := range words {
+			if contains(codes, word) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// errorCode returns the code of a cloud SDK error in the chain of err
+func errorCode(err error) (string, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch coded := err.(type) {
+		case interface{ ErrorCode() string }:
+			return coded.ErrorCode(), true
+		case interface{ Code() string }:
+			return coded.Code(), true
+		}
+	}
+	return "", false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
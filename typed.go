@@ -0,0 +1,43 @@
+package retry
+
+import "context"
+
+// DoTyped calls a function returning a value and an error until it succeeds,
+// see Retry.DoContext. Errors are classified by WithRetryIf, every error is retried
+// by default. The value returned by the last call is returned.
+func DoTyped[T any](ctx context.Context, call func(ctx context.Context) (T, error), opts ...Option) (T, error) {
+	return doTyped(ctx, New(Config{}.With(opts...)), call)
+}
+
+func doTyped[T any](ctx context.Context, r Retry, call func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := r.DoContext(ctx, Ctx(func(ctx context.Context) (err error) {
+		result, err = call(ctx)
+		return err
+	}, r.retryIf))
+	return result, err
+}
+
+// Do2 works same as DoTyped for functions returning two values and an error,
+// e.g. items and a cursor of a paginated API.
+func Do2[A, B any](ctx context.Context, call func(ctx context.Context) (A, B, error), opts ...Option) (A, B, error) {
+	var b B
+	a, err := DoTyped(ctx, func(ctx context.Context) (a A, err error) {
+		a, b, err = call(ctx)
+		return a, err
+	}, opts...)
+	return a, b, err
+}
+
+// Stage wraps fn of a pipeline stage, so every item is retried with
+// the policy, errors are classified by classifier, every error is retried
+// if it is nil. The returned function is safe for concurrent use
+// by the goroutines of the stage.
+func Stage[I, O any](policy Retry, fn func(ctx context.Context, in I) (O, error), classifier Classifier) func(ctx context.Context, in I) (O, error) {
+	policy.retryIf = classifier
+	return func(ctx context.Context, in I) (O, error) {
+		return doTyped(ctx, policy, func(ctx context.Context) (O, error) {
+			return fn(ctx, in)
+		})
+	}
+}
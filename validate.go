@@ -0,0 +1,101 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidConfig is matched by errors.Is for errors of Config.Validate.
+var ErrInvalidConfig = errors.New("invalid retry config")
+
+// NewStrict works same as New, but returns an error for invalid cfg
+// instead of silently replacing invalid values with defaults.
+// Options are validated as well, when applied to Config:
+//
+//	retry.NewStrict(retry.Config{}.With(opts...))
+func NewStrict(cfg Config) (Retry, error) {
+	if err := cfg.Validate(); err != nil {
+		return Retry{}, err
+	}
+	return New(cfg), nil
+}
+
+// Validate returns an error listing invalid fields of Config.
+func (cfg Config) Validate() error {
+	var problems []string
+	invalid := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if cfg.Attempts < 1 {
+		invalid("attempts %d must be positive", cfg.Attempts)
+	}
+	if cfg.Backoff < 0 {
+		invalid("backoff %s must not be negative", cfg.Backoff)
+	}
+	if cfg.Jitter < 0 || cfg.Jitter >= 1 {
+		invalid("jitter %g out of range [0.0, 1.0)", cfg.Jitter)
+	}
+	if cfg.Multiplier < 0 {
+		invalid("multiplier %g must not be negative", cfg.Multiplier)
+	}
+	if cfg.JitterDuration < 0 {
+		invalid("jitter duration %s must not be negative", cfg.JitterDuration)
+	}
+	if cfg.MaxBackoff < 0 {
+		invalid("max backoff %s must not be negative", cfg.MaxBackoff)
+	}
+	if cfg.MaxBackoff > 0 && cfg.MaxBackoff < cfg.Backoff {
+		invalid("max backoff %s is less than backoff %s", cfg.MaxBackoff, cfg.Backoff)
+	}
+	if cfg.MinBackoff < 0 {
+		invalid("min backoff %s must not be negative", cfg.MinBackoff)
+	}
+	if cfg.MinBackoff > 0 && cfg.MaxBackoff > 0 && cfg.MaxBackoff < cfg.MinBackoff {
+		invalid("max backoff %s is less than min backoff %s", cfg.MaxBackoff, cfg.MinBackoff)
+	}
+	if cfg.ImmediateRetries < 0 {
+		invalid("immediate retries %d must not be negative", cfg.ImmediateRetries)
+	}
+	if cfg.RetryProbability < 0 || cfg.RetryProbability > 1 {
+		invalid("retry probability %g out of range [0.0, 1.0]", cfg.RetryProbability)
+	}
+	if cfg.AttemptTimeout < 0 {
+		invalid("attempt timeout %s must not be negative", cfg.AttemptTimeout)
+	}
+	if cfg.MaxRetries < 0 {
+		invalid("max retries %d must not be negative", cfg.MaxRetries)
+	}
+	if cfg.MaxRetries > 0 && cfg.MaxRetriesWindow <= 0 {
+		invalid("max retries window %s must be positive", cfg.MaxRetriesWindow)
+	}
+	if cfg.Alignment < 0 {
+		invalid("alignment %s must not be negative", cfg.Alignment)
+	}
+	if cfg.Hours != nil && (cfg.Hours.From < 0 || cfg.Hours.From >= cfg.Hours.To) {
+		invalid("hours %s..%s are empty", cfg.Hours.From, cfg.Hours.To)
+	}
+	if cfg.AttemptsByClass != nil && cfg.ClassOf == nil {
+		invalid("attempts by class are set without class classifier")
+	}
+	if cfg.Policies != nil && cfg.SwitchClassOf == nil {
+		invalid("switch policies are set without class classifier")
+	}
+	for class, retries := range cfg.AttemptsByClass {
+		if retries < 0 {
+			invalid("retries %d of class %q must not be negative", retries, class)
+		}
+	}
+	if cfg.SoftDeadline < 0 {
+		invalid("soft deadline %s must not be negative", cfg.SoftDeadline)
+	}
+	if cfg.Throttled != nil && cfg.RateLimiter == nil {
+		invalid("throttled classifier is set without rate limiter")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(problems, "; "))
+}